@@ -1,14 +1,20 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"github.com/VlasovArtem/hob-migration/src/client"
 	"github.com/VlasovArtem/hob-migration/src/config"
+	"github.com/VlasovArtem/hob-migration/src/migration"
 	"github.com/VlasovArtem/hob-migration/src/migrator"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
-	"io/ioutil"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	// the time zones of the migrator files do not depend on the time zone database of the system
+	_ "time/tzdata"
 )
 
 func main() {
@@ -19,61 +25,46 @@ func main() {
 
 	log.Info().Msg(fmt.Sprintf("Config details: \n%s", cmdConfig.String()))
 
-	hobClient := client.NewHobClient(cmdConfig)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 
-	validateRequest(cmdConfig, hobClient)
+	go func() {
+		<-ctx.Done()
+		// the next signal terminates the process, e.g. if the rollback takes too long
+		stop()
+		log.Warn().Msg("Interrupted, waiting for the requests in progress, press Ctrl-C again to exit immediately")
+	}()
 
-	requestMigrator := readRequestMigrator(cmdConfig)
-
-	var rollbackOperation []func()
-
-	groupMap, rollbackOperation := migrator.
-		NewGroupMigrator(requestMigrator, cmdConfig, hobClient).
-		Migrate(rollbackOperation)
-
-	houseMap, rollbackOperation := migrator.
-		NewHouseMigrator(requestMigrator, cmdConfig, hobClient, groupMap).
-		Migrate(rollbackOperation)
-
-	_, rollbackOperation = migrator.
-		NewIncomeMigrator(requestMigrator, hobClient, houseMap, groupMap).
-		Migrate(rollbackOperation)
-
-	_, rollbackOperation = migrator.
-		NewPaymentMigrator(requestMigrator, cmdConfig, hobClient, houseMap).
-		Migrate(rollbackOperation)
-
-	log.Info().Msg("Completed hob-migration")
-}
-
-func validateRequest(cmdConfig *config.CMDConfig, hobClient *client.HobClient) {
-	err := hobClient.HealthCheck()
-
-	if err != nil {
-		log.Fatal().Err(err).Msg("Hob API is not available")
-		return
-	}
-
-	if !hobClient.UserExists(cmdConfig.UserId) {
-		log.Fatal().Msg(fmt.Sprintf("user with %s not found", cmdConfig.UserId))
+	if err := run(ctx, *cmdConfig, os.Stdout); err != nil {
+		log.Fatal().Err(err).Msgf("Failed to %s", cmdConfig.Command)
 	}
 }
 
-func readRequestMigrator(cmdConfig *config.CMDConfig) (migrator migrator.RequestMigrator) {
-	open, err := os.Open(cmdConfig.MigratorFilePath)
-	if err != nil {
-		log.Fatal().Err(err).Msgf("Failed to open migrator file %s", cmdConfig.MigratorFilePath)
-	}
-	bytes, err := ioutil.ReadAll(open)
-	if err != nil {
-		log.Fatal().Err(err).Msgf("Failed to read migrator file %s", cmdConfig.MigratorFilePath)
+// run runs the command of the config, the table of the invalid rows is printed to the validation output.
+func run(ctx context.Context, cmdConfig config.CMDConfig, validationOutput io.Writer) error {
+	options := migration.Options{
+		Config:           cmdConfig,
+		ValidationOutput: validationOutput,
 	}
-	details := new(map[string]string)
-	err = json.Unmarshal(bytes, &details)
-	if err != nil {
-		log.Fatal().Err(err).Msgf("Failed to unmarshal migrator file %s", cmdConfig.MigratorFilePath)
+
+	switch cmdConfig.Command {
+	case config.MigrateCommand:
+		_, err := migration.Run(ctx, options)
+		return err
+	case config.RollbackCommand:
+		if err := migration.Rollback(ctx, options); err != nil {
+			return errors.Wrapf(err, "failed to rollback journal %s", cmdConfig.JournalPath)
+		}
+
+		log.Info().Msgf("Completed rollback of journal %s", cmdConfig.JournalPath)
+	case config.ExportCommand:
+		if err := migration.Export(ctx, options); err != nil {
+			return errors.Wrapf(err, "failed to export data of the user %s", cmdConfig.UserId)
+		}
+
+		log.Info().Msgf("Completed export, migrator file %s", filepath.Join(cmdConfig.OutputDir, migrator.ManifestFileName))
+	default:
+		return fmt.Errorf("unknown command %s", cmdConfig.Command)
 	}
 
-	migrator.TypeToPathMap = *details
-	return migrator
+	return nil
 }
@@ -0,0 +1,1093 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/VlasovArtem/hob-migration/src/config"
+	"github.com/VlasovArtem/hob-migration/src/hobfake"
+	"github.com/VlasovArtem/hob-migration/src/migration"
+	"github.com/VlasovArtem/hob-migration/src/migrator"
+	"github.com/VlasovArtem/hob-migration/src/model"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const exampleMigratorPath = "example/example.json"
+
+func newTestServer(t *testing.T) (*hobfake.Server, uuid.UUID) {
+	server := hobfake.NewServer()
+	t.Cleanup(server.Close)
+
+	userId := uuid.New()
+	server.AddUser(userId)
+
+	return server, userId
+}
+
+func newTestConfig(t *testing.T, server *hobfake.Server, userId uuid.UUID) config.CMDConfig {
+	return config.CMDConfig{
+		Command:          config.MigrateCommand,
+		HobURL:           server.URL,
+		MigratorFilePath: exampleMigratorPath,
+		UserId:           userId.String(),
+		JournalDir:       t.TempDir(),
+		ReuseExisting:    true,
+		RequestTimeout:   10 * time.Second,
+		MaxRetries:       2,
+		RetryDelay:       time.Millisecond,
+		RetryMaxDelay:    10 * time.Millisecond,
+		BatchSize:        config.Default().BatchSize,
+		Concurrency:      2,
+	}
+}
+
+func assertExampleMigrated(t *testing.T, server *hobfake.Server) {
+	t.Helper()
+
+	if groups := server.Groups(); len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+
+	houses := server.Houses()
+
+	if len(houses) != 3 {
+		t.Fatalf("expected 3 houses, got %d", len(houses))
+	}
+
+	for _, house := range houses {
+		if house.Name == "Flat" && len(house.Groups) != 2 {
+			t.Errorf("expected the flat in 2 groups, got %v", house.Groups)
+		}
+	}
+
+	if incomes := server.Incomes(); len(incomes) != 4 {
+		t.Fatalf("expected 4 incomes, got %d", len(incomes))
+	}
+
+	payments := server.Payments()
+
+	if len(payments) != 4 {
+		t.Fatalf("expected 4 payments, got %d", len(payments))
+	}
+
+	for _, payment := range payments {
+		if payment.Name == "Gas" && payment.Sum.String() != "1234.5" {
+			t.Errorf("expected the gas payment of 1234.5, got %s", payment.Sum)
+		}
+	}
+}
+
+func TestMigrateExample(t *testing.T) {
+	server, userId := newTestServer(t)
+
+	if err := run(context.Background(), newTestConfig(t, server, userId), io.Discard); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	assertExampleMigrated(t, server)
+	assertExampleProviders(t, server)
+}
+
+// assertExampleProviders checks the providers of the example and the payments linked to them.
+func assertExampleProviders(t *testing.T, server *hobfake.Server) {
+	t.Helper()
+
+	providers := make(map[uuid.UUID]model.ProviderDto)
+
+	for _, provider := range server.Providers() {
+		providers[provider.Id] = provider
+	}
+
+	if len(providers) != 2 {
+		t.Fatalf("expected 2 providers, got %d", len(providers))
+	}
+
+	expected := map[string]string{"Electricity": "City Power", "Water": "Aqua", "Internet": "", "Gas": ""}
+
+	for _, payment := range server.Payments() {
+		if name := providers[payment.ProviderId].Name; name != expected[payment.Name] {
+			t.Errorf("expected the %s payment of the provider %q, got %q", payment.Name, expected[payment.Name], name)
+		}
+	}
+}
+
+// scenario is an end-to-end test of the migration of the files written by writeTestFiles to a new fake server.
+type scenario struct {
+	name  string
+	files map[string]string
+	// invalidRows are the expected invalid rows in the order of the report, the migration is expected to succeed if
+	// there are none
+	invalidRows []invalidRow
+	// check verifies the server after the successful migration
+	check func(t *testing.T, server *hobfake.Server, cmdConfig config.CMDConfig)
+}
+
+// invalidRow is the line and the column of an invalid row.
+type invalidRow struct {
+	line   int
+	column string
+}
+
+func runScenarios(t *testing.T, scenarios []scenario) {
+	for _, test := range scenarios {
+		t.Run(test.name, func(t *testing.T) {
+			server, userId := newTestServer(t)
+
+			cmdConfig := newTestConfig(t, server, userId)
+			cmdConfig.MigratorFilePath = writeTestFiles(t, test.files)
+
+			err := run(context.Background(), cmdConfig, io.Discard)
+
+			if len(test.invalidRows) == 0 {
+				if err != nil {
+					t.Fatalf("migration failed: %v", err)
+				}
+
+				if test.check != nil {
+					test.check(t, server, cmdConfig)
+				}
+				return
+			}
+
+			var validationError *migration.ValidationError
+
+			if !errors.As(err, &validationError) {
+				t.Fatalf("expected validation error, got %v", err)
+			}
+
+			if len(validationError.Errors) != len(test.invalidRows) {
+				t.Fatalf("expected %d invalid rows, got %v", len(test.invalidRows), validationError.Errors)
+			}
+
+			for index, expected := range test.invalidRows {
+				if rowError := validationError.Errors[index]; rowError.Line != expected.line || rowError.Column != expected.column {
+					t.Errorf("expected the invalid %s at the line %d, got %v", expected.column, expected.line, rowError)
+				}
+			}
+
+			if !server.Empty() {
+				t.Error("expected no entities after the validation")
+			}
+		})
+	}
+}
+
+func TestInvalidRowsAreReported(t *testing.T) {
+	runScenarios(t, []scenario{
+		{
+			name: "unknown provider",
+			files: map[string]string{
+				"migrator.yaml": `
+groups: example/groups.csv
+houses: example/houses.csv
+providers: example/providers.csv
+payments: ${TEST_DIR}/payments.csv
+`,
+				"payments.csv": `House Identifier,Name,Description,Date,Sum,Provider
+home,Electricity,,2022-01-31,45.20,City Power
+home,Water,,2022-01-31,12.10,Aqua Inc
+`,
+			},
+			invalidRows: []invalidRow{{line: 3, column: "Provider"}},
+		},
+		{
+			name: "payment without house and groups",
+			files: map[string]string{
+				"migrator.yaml": `
+groups: example/groups.csv
+houses: example/houses.csv
+payments: ${TEST_DIR}/payments.csv
+`,
+				"payments.csv": `House Identifier,Groups,Name,Description,Date,Sum
+,Rentals,Insurance,,2022-01-31,120
+,,Unknown,,2022-01-31,1
+,Neighbours,Fence,,2022-01-31,1
+`,
+			},
+			invalidRows: []invalidRow{{line: 3, column: "House Identifier"}, {line: 4, column: "Groups"}},
+		},
+		{
+			name: "invalid dates",
+			files: map[string]string{
+				"migrator.yaml": `
+groups: example/groups.csv
+houses: example/houses.csv
+incomes: ${TEST_DIR}/incomes.csv
+`,
+				"incomes.csv": `House Identifier,Groups,Name,Description,Date,Sum
+flat,,Rent,,2022-01-05,500
+flat,,Rent,,05.02.2022,500
+flat,,Rent,,,500
+`,
+			},
+			invalidRows: []invalidRow{{line: 3, column: "Date"}, {line: 4, column: "Date"}},
+		},
+		{
+			name: "missing exchange rate",
+			files: map[string]string{
+				"migrator.yaml": `
+groups: example/groups.csv
+houses: example/houses.csv
+incomes:
+  path: ${TEST_DIR}/incomes.csv
+  currency: UAH
+  exchangeRates: ${TEST_DIR}/rates.csv
+`,
+				"incomes.csv": `House Identifier,Groups,Name,Description,Date,Sum,Currency
+flat,,Rent,,2022-01-05,500,EUR
+flat,,Rent,,2021-12-31,500,EUR
+flat,,Rent,,2022-01-05,500,USD
+`,
+				"rates.csv": `Date,Currency,Rate
+2022-01-03,EUR,30.9226
+`,
+			},
+			invalidRows: []invalidRow{{line: 3, column: "Currency"}, {line: 4, column: "Currency"}},
+		},
+		{
+			name: "invalid transaction of an ofx file of incomes and payments",
+			files: map[string]string{
+				"migrator.yaml": `
+groups: example/groups.csv
+houses: example/houses.csv
+incomes:
+  path: ${TEST_DIR}/statement.ofx
+  house: home
+payments:
+  path: ${TEST_DIR}/statement.ofx
+  house: home
+`,
+				"statement.ofx": `<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><BANKTRANLIST>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20220105<TRNAMT>500.00<FITID>1<NAME>Salary</STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20220107<TRNAMT>-12.10<FITID>2<NAME>Water</STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20220110<TRNAMT>abc<FITID>3<NAME>Gas</STMTTRN>
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>
+`,
+			},
+			invalidRows: []invalidRow{{line: 3, column: "TRNAMT"}},
+		},
+	})
+}
+
+func TestMigrateFiles(t *testing.T) {
+	runScenarios(t, []scenario{
+		{
+			name: "payments of groups",
+			files: map[string]string{
+				"migrator.yaml": `
+groups: example/groups.csv
+houses: example/houses.csv
+payments: ${TEST_DIR}/payments.json
+`,
+				"payments.json": `[
+  {"groups": ["Family", "Rentals"], "name": "Insurance", "date": "2022-01-31", "sum": 120},
+  {"houseIdentifier": "home", "name": "Water", "date": "2022-01-31", "sum": 12.1}
+]`,
+			},
+			check: func(t *testing.T, server *hobfake.Server, cmdConfig config.CMDConfig) {
+				payments := paymentsByName(server)
+
+				if insurance := payments["Insurance"]; len(insurance.Groups) != 2 || insurance.HouseId != uuid.Nil {
+					t.Errorf("expected the insurance of 2 groups without a house, got %+v", insurance)
+				}
+
+				if water := payments["Water"]; len(water.Groups) != 0 || water.HouseId == uuid.Nil {
+					t.Errorf("expected the water of the house, got %+v", water)
+				}
+
+				cmdConfig.Command = config.ExportCommand
+				cmdConfig.OutputDir = t.TempDir()
+
+				if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+					t.Fatalf("export failed: %v", err)
+				}
+
+				exported, err := os.ReadFile(filepath.Join(cmdConfig.OutputDir, "payments.csv"))
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !strings.Contains(string(exported), `,"Family,Rentals",Insurance,`) {
+					t.Errorf("expected the insurance exported with its groups, got %s", exported)
+				}
+			},
+		},
+		{
+			name: "date layouts and time zone",
+			files: map[string]string{
+				"migrator.yaml": `
+groups: example/groups.csv
+houses: example/houses.csv
+payments:
+  path: ${TEST_DIR}/payments.csv
+  dateLayouts: ["02.01.2006", "excel"]
+  timeZone: Europe/Kyiv
+`,
+				"payments.csv": `House Identifier,Name,Description,Date,Sum
+home,Layout,,31.01.2022,1
+home,Excel,,44592,1
+home,Offset,,2022-01-31T00:00:00+02:00,1
+`,
+			},
+			check: func(t *testing.T, server *hobfake.Server, cmdConfig config.CMDConfig) {
+				payments := server.Payments()
+
+				if len(payments) != 3 {
+					t.Fatalf("expected 3 payments, got %d", len(payments))
+				}
+
+				for _, payment := range payments {
+					if date := payment.Date.UTC().Format(time.RFC3339); date != "2022-01-30T22:00:00Z" {
+						t.Errorf("expected the %s payment at 2022-01-30T22:00:00Z, got %s", payment.Name, date)
+					}
+				}
+			},
+		},
+		{
+			name: "currencies converted with exchange rates",
+			files: map[string]string{
+				"migrator.yaml": `
+groups: example/groups.csv
+houses: example/houses.csv
+payments:
+  path: ${TEST_DIR}/payments.csv
+  currency: UAH
+  exchangeRates: ${TEST_DIR}/rates.csv
+`,
+				"payments.csv": `House Identifier,Name,Description,Date,Sum,Currency
+home,Local,,2022-01-03,100,
+home,Rent,Flat,2022-01-03,100,EUR
+home,Weekend,,2022-01-09,10.5,EUR
+`,
+				"rates.csv": `Date,Currency,Rate
+2022-01-03,EUR,30.9226
+2022-01-06,EUR,31.0012
+`,
+			},
+			check: func(t *testing.T, server *hobfake.Server, cmdConfig config.CMDConfig) {
+				payments := paymentsByName(server)
+
+				expected := map[string][2]string{
+					"Local":   {"100", ""},
+					"Rent":    {"3092.26", "Flat (100 EUR)"},
+					"Weekend": {"325.51", "10.5 EUR"},
+				}
+
+				for name, values := range expected {
+					if payment := payments[name]; payment.Sum.String() != values[0] || payment.Description != values[1] {
+						t.Errorf("expected the %s payment of %s with the description %q, got %s and %q", name, values[0], values[1], payment.Sum, payment.Description)
+					}
+				}
+			},
+		},
+	})
+}
+
+func TestReferenceExistingHousesAndGroups(t *testing.T) {
+	server, userId := newTestServer(t)
+
+	if err := run(context.Background(), newTestConfig(t, server, userId), io.Discard); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	houses := make(map[string]model.HouseDto)
+
+	for _, house := range server.Houses() {
+		houses[house.Name] = house
+	}
+
+	cmdConfig := newTestConfig(t, server, userId)
+	cmdConfig.MigratorFilePath = writeTestFiles(t, map[string]string{
+		"migrator.yaml": `
+incomes: ${TEST_DIR}/incomes.csv
+payments: ${TEST_DIR}/payments.csv
+`,
+		"incomes.csv": `House Identifier,Groups,Name,Description,Date,Sum
+,Rentals,Rent,,2022-03-05,500
+`,
+		"payments.csv": `House Identifier,Name,Description,Date,Sum
+` + houses["Cottage"].Id.String() + `,Gas,March,2022-03-31,90
+Home,Water,March,2022-03-31,12
+`,
+	})
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+		t.Fatalf("migration of the references failed: %v", err)
+	}
+
+	if groups, houses := server.Groups(), server.Houses(); len(groups) != 2 || len(houses) != 3 {
+		t.Fatalf("expected the existing 2 groups and 3 houses, got %d and %d", len(groups), len(houses))
+	}
+
+	for _, payment := range server.Payments() {
+		if payment.Description != "March" {
+			continue
+		}
+
+		if expected := map[string]string{"Gas": "Cottage", "Water": "Home"}[payment.Name]; payment.HouseId != houses[expected].Id {
+			t.Errorf("expected the %s payment of the %s house, got %s", payment.Name, expected, payment.HouseId)
+		}
+	}
+
+	if incomes := server.Incomes(); len(incomes) != 5 || !hasIncomeOfGroup(incomes, "Rentals") {
+		t.Errorf("expected the rent of the existing Rentals group, got %v", incomes)
+	}
+}
+
+func TestAmbiguousExistingHouseIsInvalidRow(t *testing.T) {
+	server, userId := newTestServer(t)
+
+	cmdConfig := newTestConfig(t, server, userId)
+	cmdConfig.MigratorFilePath = writeTestFiles(t, map[string]string{
+		"migrator.yaml": `houses: ${TEST_DIR}/houses.csv`,
+		"houses.csv": `House Identifier,Groups,Name,Country,City,Address 1,Address 2
+first,,Garage,UA,Kyiv,Khreshchatyk 1,
+second,,Garage,UA,Lviv,Svobody 5,
+`,
+	})
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	cmdConfig.MigratorFilePath = writeTestFiles(t, map[string]string{
+		"migrator.yaml": `payments: ${TEST_DIR}/payments.csv`,
+		"payments.csv": `House Identifier,Name,Description,Date,Sum
+Garage,Cleaning,,2022-03-31,10
+`,
+	})
+
+	err := run(context.Background(), cmdConfig, io.Discard)
+
+	var validationError *migration.ValidationError
+
+	if !errors.As(err, &validationError) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	if len(validationError.Errors) != 1 || !strings.Contains(validationError.Errors[0].Reason, "reference the house by id") {
+		t.Errorf("expected the ambiguous house, got %v", validationError.Errors)
+	}
+}
+
+func TestMigrateYAMLExample(t *testing.T) {
+	server, userId := newTestServer(t)
+	t.Setenv("EXAMPLE_DIR", "example")
+
+	cmdConfig := newTestConfig(t, server, userId)
+	cmdConfig.MigratorFilePath = "example/example.yaml"
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	assertExampleMigrated(t, server)
+
+	for _, payment := range server.Payments() {
+		if payment.Name == "Gas" && (payment.Description != "Газ, зима" || payment.Date.Format("2006-01-02") != "2022-02-28") {
+			t.Errorf("expected the gas payment of 2022-02-28 with the description Газ, зима, got %s %s", payment.Date, payment.Description)
+		}
+	}
+}
+
+func TestInvalidManifest(t *testing.T) {
+	tests := map[string]string{
+		"unknown entity type": `{"payment": "example/payments.csv"}`,
+		"unknown option":      `{"payments": {"path": "example/payments.csv", "dateLayot": "02.01.2006"}}`,
+		"unset variable":      `{"payments": "${HOB_MIGRATION_UNSET}/payments.csv"}`,
+		"unknown encoding":    `{"payments": {"path": "example/payments.csv", "encoding": "utf-42"}}`,
+		"unknown version":     `{"version": 3, "payments": "example/payments.csv"}`,
+		"house of a csv file": `{"payments": {"path": "example/payments.csv", "house": "home"}}`,
+	}
+
+	for name, manifest := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, userId := newTestServer(t)
+
+			cmdConfig := newTestConfig(t, server, userId)
+			cmdConfig.MigratorFilePath = filepath.Join(t.TempDir(), "migrator.json")
+
+			if err := os.WriteFile(cmdConfig.MigratorFilePath, []byte(manifest), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := run(context.Background(), cmdConfig, io.Discard)
+
+			var configError *migration.ConfigError
+
+			if !errors.As(err, &configError) {
+				t.Fatalf("expected config error, got %v", err)
+			}
+
+			if len(server.Requests()) != 0 {
+				t.Errorf("expected no requests, got %v", server.Requests())
+			}
+		})
+	}
+}
+
+func TestMigrateExampleTwiceReusesGroupsAndHouses(t *testing.T) {
+	server, userId := newTestServer(t)
+	cmdConfig := newTestConfig(t, server, userId)
+
+	for i := 0; i < 2; i++ {
+		if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+			t.Fatalf("migration %d failed: %v", i+1, err)
+		}
+	}
+
+	if groups := server.Groups(); len(groups) != 2 {
+		t.Errorf("expected 2 groups, got %d", len(groups))
+	}
+
+	if houses := server.Houses(); len(houses) != 3 {
+		t.Errorf("expected 3 houses, got %d", len(houses))
+	}
+
+	if payments := server.Payments(); len(payments) != 8 {
+		t.Errorf("expected 8 payments, got %d", len(payments))
+	}
+}
+
+func TestDryRunSendsNoData(t *testing.T) {
+	server, userId := newTestServer(t)
+	cmdConfig := newTestConfig(t, server, userId)
+	cmdConfig.DryRun = true
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+
+	for _, request := range server.Requests() {
+		if strings.HasPrefix(request, http.MethodPost) {
+			t.Errorf("dry run sent %s", request)
+		}
+	}
+}
+
+func TestUnknownUser(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	err := run(context.Background(), newTestConfig(t, server, uuid.New()), io.Discard)
+
+	if !errors.Is(err, migration.ErrUserNotFound) {
+		t.Fatalf("expected user not found, got %v", err)
+	}
+}
+
+func TestFailedPaymentsRollBack(t *testing.T) {
+	server, userId := newTestServer(t)
+	server.Inject(hobfake.Fault{Method: http.MethodPost, Path: "/api/v1/payments", StatusCode: http.StatusInternalServerError})
+
+	err := run(context.Background(), newTestConfig(t, server, userId), io.Discard)
+
+	var migrationError *migration.MigrationError
+
+	if !errors.As(err, &migrationError) {
+		t.Fatalf("expected migration error, got %v", err)
+	}
+
+	if migrationError.Rollback == nil || migrationError.Rollback.Failed != 0 || migrationError.Rollback.Deleted != 11 {
+		t.Errorf("expected 11 deleted entities, got %+v", migrationError.Rollback)
+	}
+
+	if !server.Empty() {
+		t.Errorf("expected no entities after the rollback, got %d groups, %d houses, %d incomes", len(server.Groups()), len(server.Houses()), len(server.Incomes()))
+	}
+}
+
+func TestResumeFailedPaymentsWithoutDuplicates(t *testing.T) {
+	server, userId := newTestServer(t)
+	// the second batch of payments fails once, the first batch is created
+	server.Inject(hobfake.Fault{Method: http.MethodPost, Path: "/api/v1/payments/batch", Nth: 2, Times: 1, StatusCode: http.StatusBadRequest})
+
+	cmdConfig := newTestConfig(t, server, userId)
+	cmdConfig.RunId = "failed-payments"
+	cmdConfig.KeepOnFailure = true
+	cmdConfig.MigratorFilePath = writeTestFiles(t, map[string]string{
+		"migrator.yaml": `
+groups: example/groups.csv
+houses: example/houses.csv
+payments:
+  path: ${TEST_DIR}/payments.json
+  batchSize: 2
+`,
+		"payments.json": `[
+  {"houseIdentifier": "home", "name": "Water", "date": "2022-01-31", "sum": 12.1},
+  {"houseIdentifier": "home", "name": "Gas", "date": "2022-01-31", "sum": 30},
+  {"houseIdentifier": "home", "name": "Internet", "date": "2022-01-31", "sum": 10},
+  {"houseIdentifier": "home", "name": "Internet", "date": "2022-01-31", "sum": 10}
+]`,
+	})
+
+	err := run(context.Background(), cmdConfig, io.Discard)
+
+	var migrationError *migration.MigrationError
+
+	if !errors.As(err, &migrationError) || migrationError.Rollback != nil {
+		t.Fatalf("expected migration error without a rollback, got %v", err)
+	}
+
+	if payments := server.Payments(); len(payments) != 2 {
+		t.Fatalf("expected 2 payments kept after the failure, got %d", len(payments))
+	}
+
+	// a row inserted before the created rows does not shift the restored payments
+	if err := os.WriteFile(filepath.Join(os.Getenv("TEST_DIR"), "payments.json"), []byte(`[
+  {"houseIdentifier": "home", "name": "Rent", "date": "2022-01-31", "sum": 500},
+  {"houseIdentifier": "home", "name": "Water", "date": "2022-01-31", "sum": 12.1},
+  {"houseIdentifier": "home", "name": "Gas", "date": "2022-01-31", "sum": 30},
+  {"houseIdentifier": "home", "name": "Internet", "date": "2022-01-31", "sum": 10},
+  {"houseIdentifier": "home", "name": "Internet", "date": "2022-01-31", "sum": 10}
+]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmdConfig.Resume = cmdConfig.RunId
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+		t.Fatalf("resumed migration failed: %v", err)
+	}
+
+	names := make(map[string]int)
+
+	for _, payment := range server.Payments() {
+		names[payment.Name]++
+	}
+
+	if len(server.Payments()) != 5 || names["Rent"] != 1 || names["Water"] != 1 || names["Gas"] != 1 || names["Internet"] != 2 {
+		t.Errorf("expected every payment of the file once, got %v", names)
+	}
+
+	if groups, houses := server.Groups(), server.Houses(); len(groups) != 2 || len(houses) != 3 {
+		t.Errorf("expected 2 groups and 3 houses, got %d and %d", len(groups), len(houses))
+	}
+}
+
+func TestServerErrorIsRetried(t *testing.T) {
+	server, userId := newTestServer(t)
+	server.Inject(hobfake.Fault{Method: http.MethodPost, Path: "/api/v1/incomes/batch", Nth: 1, Times: 2, StatusCode: http.StatusServiceUnavailable})
+
+	if err := run(context.Background(), newTestConfig(t, server, userId), io.Discard); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	assertExampleMigrated(t, server)
+}
+
+func TestGatewayTimeoutOfCreatedEntitiesIsNotRetried(t *testing.T) {
+	server, userId := newTestServer(t)
+	server.Inject(hobfake.Fault{Method: http.MethodPost, Path: "/api/v1/groups/batch", Nth: 1, Times: 1, StatusCode: http.StatusGatewayTimeout, Handled: true})
+
+	err := run(context.Background(), newTestConfig(t, server, userId), io.Discard)
+
+	var migrationError *migration.MigrationError
+
+	if !errors.As(err, &migrationError) {
+		t.Fatalf("expected migration error, got %v", err)
+	}
+
+	if requests := countRequests(server, http.MethodPost+" /api/v1/groups/batch"); requests != 1 {
+		t.Errorf("expected the groups batch to be sent once, got %d", requests)
+	}
+
+	// the groups created by the server are unknown to the migration, so they are not rolled back
+	if groups := server.Groups(); len(groups) != 2 {
+		t.Errorf("expected the 2 groups created once, got %d", len(groups))
+	}
+}
+
+func TestLargeBatchesAreSplit(t *testing.T) {
+	server, userId := newTestServer(t)
+	server.SetMaxBatchSize(1)
+
+	if err := run(context.Background(), newTestConfig(t, server, userId), io.Discard); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	assertExampleMigrated(t, server)
+
+	// the batch of 2 groups is rejected, then the groups are created one per batch
+	if requests := countRequests(server, http.MethodPost+" /api/v1/groups/batch"); requests != 3 {
+		t.Errorf("expected 3 group batches, got %d", requests)
+	}
+}
+
+func TestHousesAreCreatedOneByOneWithoutBatchEndpoint(t *testing.T) {
+	server, userId := newTestServer(t)
+	server.DisableBatch("houses")
+	// the houses are delayed, so the requests overlap up to the concurrency
+	server.Inject(hobfake.Fault{Method: http.MethodPost, Path: "/api/v1/houses", Delay: 50 * time.Millisecond})
+
+	cmdConfig := newTestConfig(t, server, userId)
+	cmdConfig.Concurrency = 2
+
+	result, err := migration.Run(context.Background(), migration.Options{Config: cmdConfig})
+
+	if err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	assertExampleMigrated(t, server)
+
+	if requests := countRequests(server, http.MethodPost+" /api/v1/houses"); requests != 3 {
+		t.Errorf("expected 3 houses created one by one, got %d requests", requests)
+	}
+
+	if concurrent := server.MaxConcurrentRequests(); concurrent != 2 {
+		t.Errorf("expected 2 concurrent requests, got %d", concurrent)
+	}
+
+	for identifier, name := range map[string]string{"home": "Home", "flat": "Flat", "cottage": "Cottage"} {
+		if house := result.Houses[identifier]; house.Name != name {
+			t.Errorf("expected the house %s mapped to %s, got %+v", identifier, name, house)
+		}
+	}
+}
+
+func TestHousesAfterBatchEndpointIsGoneAreCreatedOneByOne(t *testing.T) {
+	server, userId := newTestServer(t)
+	server.Inject(hobfake.Fault{Method: http.MethodPost, Path: "/api/v1/houses/batch", Nth: 2, StatusCode: http.StatusNotFound})
+
+	cmdConfig := newTestConfig(t, server, userId)
+	cmdConfig.BatchSize = 2
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	assertExampleMigrated(t, server)
+
+	if requests := countRequests(server, http.MethodPost+" /api/v1/houses"); requests != 1 {
+		t.Errorf("expected the last house created one by one, got %d requests", requests)
+	}
+}
+
+func TestSlowResponseTimesOut(t *testing.T) {
+	server, userId := newTestServer(t)
+	server.Inject(hobfake.Fault{Method: http.MethodPost, Path: "/api/v1/houses", Delay: 300 * time.Millisecond})
+
+	cmdConfig := newTestConfig(t, server, userId)
+	cmdConfig.RequestTimeout = 100 * time.Millisecond
+	// the houses have no groups, so they can be created after the rollback
+	cmdConfig.MigratorFilePath = writeTestFiles(t, map[string]string{
+		"migrator.yaml": `houses: ${TEST_DIR}/houses.csv`,
+		"houses.csv": `House Identifier,Groups,Name,Country,City,Address 1,Address 2
+home,,Home,UA,Kyiv,Khreshchatyk 1,apt. 10
+cottage,,Cottage,PL,Krakow,Florianska 3,
+`,
+	})
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err == nil {
+		t.Fatal("expected the migration to fail")
+	}
+
+	// the server completes the request after the client timed out
+	for deadline := time.Now().Add(2 * time.Second); len(server.Houses()) < 2 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if requests := countRequests(server, http.MethodPost+" /api/v1/houses/batch"); requests != 1 {
+		t.Errorf("expected the houses batch to be sent once, got %d", requests)
+	}
+
+	if houses := server.Houses(); len(houses) != 2 {
+		t.Errorf("expected the 2 houses created once, got %d", len(houses))
+	}
+}
+
+func TestGatewayTimeoutOfBatchIsNotSentAgain(t *testing.T) {
+	server, userId := newTestServer(t)
+	server.Inject(hobfake.Fault{Method: http.MethodPost, Path: "/api/v1/incomes/batch", Nth: 1, Times: 1, StatusCode: http.StatusGatewayTimeout, Handled: true})
+
+	if err := run(context.Background(), newTestConfig(t, server, userId), io.Discard); err == nil {
+		t.Fatal("expected the migration to fail")
+	}
+
+	if requests := countRequests(server, http.MethodPost+" /api/v1/incomes/batch"); requests != 1 {
+		t.Errorf("expected the incomes batch to be sent once, got %d", requests)
+	}
+
+	// the incomes created by the server are unknown to the migration, so they are not rolled back
+	if incomes := server.Incomes(); len(incomes) != 4 {
+		t.Errorf("expected the 4 incomes created once, got %d", len(incomes))
+	}
+}
+
+func TestIncompleteBatchResponseRollsBack(t *testing.T) {
+	for _, entityType := range []string{"groups", "houses", "providers", "incomes", "payments"} {
+		t.Run(entityType, func(t *testing.T) {
+			server, userId := newTestServer(t)
+			server.Inject(hobfake.Fault{Method: http.MethodPost, Path: "/api/v1/" + entityType + "/batch", Truncate: true})
+
+			if err := run(context.Background(), newTestConfig(t, server, userId), io.Discard); err == nil {
+				t.Fatal("expected the migration to fail")
+			}
+
+			// the entities of the incomplete response are not matched with the rows, but are rolled back
+			if !server.Empty() {
+				t.Errorf("expected no entities after the rollback, got %d groups, %d houses, %d providers, %d incomes, %d payments",
+					len(server.Groups()), len(server.Houses()), len(server.Providers()), len(server.Incomes()), len(server.Payments()))
+			}
+		})
+	}
+}
+
+func TestResumeDeletesUnmatchedEntities(t *testing.T) {
+	server, userId := newTestServer(t)
+	server.Inject(hobfake.Fault{Method: http.MethodPost, Path: "/api/v1/incomes/batch", Times: 1, Truncate: true})
+
+	cmdConfig := newTestConfig(t, server, userId)
+	cmdConfig.RunId = "incomplete-incomes"
+	cmdConfig.KeepOnFailure = true
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err == nil {
+		t.Fatal("expected the migration to fail")
+	}
+
+	if incomes := server.Incomes(); len(incomes) != 3 {
+		t.Fatalf("expected 3 incomes kept after the failure, got %d", len(incomes))
+	}
+
+	cmdConfig.Resume = cmdConfig.RunId
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+		t.Fatalf("resumed migration failed: %v", err)
+	}
+
+	// the unmatched incomes are deleted and their rows are created again
+	if incomes := server.Incomes(); len(incomes) != 4 {
+		t.Errorf("expected 4 incomes, got %d", len(incomes))
+	}
+}
+
+func TestInterruptRollsBack(t *testing.T) {
+	server, userId := newTestServer(t)
+	server.Inject(hobfake.Fault{Method: http.MethodPost, Path: "/api/v1/incomes/batch", Delay: 200 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		for !contains(server.Requests(), http.MethodPost+" /api/v1/incomes/batch") {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+	}()
+
+	err := run(ctx, newTestConfig(t, server, userId), io.Discard)
+
+	if !errors.Is(err, migration.ErrInterrupted) {
+		t.Fatalf("expected interrupted migration, got %v", err)
+	}
+
+	if !server.Empty() {
+		t.Errorf("expected no entities after the rollback, got %d incomes", len(server.Incomes()))
+	}
+}
+
+func TestRollbackJournal(t *testing.T) {
+	server, userId := newTestServer(t)
+	cmdConfig := newTestConfig(t, server, userId)
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	journals, err := filepath.Glob(filepath.Join(cmdConfig.JournalDir, "*.journal"))
+
+	if err != nil || len(journals) != 1 {
+		t.Fatalf("expected 1 journal, got %v, %v", journals, err)
+	}
+
+	cmdConfig.Command = config.RollbackCommand
+	cmdConfig.JournalPath = journals[0]
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+
+	if !server.Empty() {
+		t.Error("expected no entities after the rollback")
+	}
+}
+
+func TestExportMigratesBack(t *testing.T) {
+	server, userId := newTestServer(t)
+	cmdConfig := newTestConfig(t, server, userId)
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	cmdConfig.Command = config.ExportCommand
+	cmdConfig.OutputDir = t.TempDir()
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	target, targetUserId := newTestServer(t)
+	targetConfig := newTestConfig(t, target, targetUserId)
+	targetConfig.MigratorFilePath = filepath.Join(cmdConfig.OutputDir, migrator.ManifestFileName)
+
+	if err := run(context.Background(), targetConfig, io.Discard); err != nil {
+		t.Fatalf("migration of the export failed: %v", err)
+	}
+
+	assertExampleMigrated(t, target)
+	assertExampleProviders(t, target)
+
+	if sum(target.Incomes()) != sum(server.Incomes()) {
+		t.Errorf("expected incomes of %s, got %s", sum(server.Incomes()), sum(target.Incomes()))
+	}
+}
+
+func TestExportRoundTripKeepsSums(t *testing.T) {
+	server, userId := newTestServer(t)
+
+	cmdConfig := newTestConfig(t, server, userId)
+	cmdConfig.MigratorFilePath = writeTestFiles(t, map[string]string{
+		"migrator.yaml": `
+groups: example/groups.csv
+houses: example/houses.csv
+incomes:
+  path: ${TEST_DIR}/incomes.csv
+  decimalSeparator: "."
+payments:
+  path: ${TEST_DIR}/payments.csv
+  decimalSeparator: "."
+`,
+		"incomes.csv": `House Identifier,Groups,Name,Description,Date,Sum
+flat,,Rent,,2022-01-05,12.345
+`,
+		"payments.csv": `House Identifier,Name,Description,Date,Sum
+home,Water,,2022-01-31,1.500
+home,Gas,,2022-01-31,1234.567
+`,
+	})
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	cmdConfig.Command = config.ExportCommand
+	cmdConfig.OutputDir = t.TempDir()
+
+	if err := run(context.Background(), cmdConfig, io.Discard); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	target, targetUserId := newTestServer(t)
+	targetConfig := newTestConfig(t, target, targetUserId)
+	targetConfig.MigratorFilePath = filepath.Join(cmdConfig.OutputDir, migrator.ManifestFileName)
+
+	if err := run(context.Background(), targetConfig, io.Discard); err != nil {
+		t.Fatalf("migration of the export failed: %v", err)
+	}
+
+	sums := func(server *hobfake.Server) map[string]string {
+		sums := make(map[string]string)
+		for _, income := range server.Incomes() {
+			sums[income.Name] = income.Sum.String()
+		}
+		for _, payment := range server.Payments() {
+			sums[payment.Name] = payment.Sum.String()
+		}
+		return sums
+	}
+
+	expected := map[string]string{"Rent": "12.345", "Water": "1.5", "Gas": "1234.567"}
+
+	for name, sum := range sums(target) {
+		if sum != expected[name] {
+			t.Errorf("expected the %s of %s after the export, got %s", name, expected[name], sum)
+		}
+	}
+
+	if len(sums(target)) != len(expected) {
+		t.Errorf("expected %d incomes and payments after the export, got %v", len(expected), sums(target))
+	}
+}
+
+// writeTestFiles writes the files to a temporary directory and returns the path of the migrator file, the file named
+// migrator. TEST_DIR is the directory of the files in the migrator file.
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	t.Setenv("TEST_DIR", dir)
+
+	var migratorPath string
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+
+		if strings.HasPrefix(name, "migrator.") {
+			migratorPath = path
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return migratorPath
+}
+
+// countRequests returns the number of the requests of the server with the method and the path.
+func countRequests(server *hobfake.Server, request string) int {
+	count := 0
+
+	for _, received := range server.Requests() {
+		if received == request {
+			count++
+		}
+	}
+
+	return count
+}
+
+func paymentsByName(server *hobfake.Server) map[string]model.PaymentDto {
+	payments := make(map[string]model.PaymentDto)
+
+	for _, payment := range server.Payments() {
+		payments[payment.Name] = payment
+	}
+
+	return payments
+}
+
+func contains(items []string, item string) bool {
+	for _, existing := range items {
+		if existing == item {
+			return true
+		}
+	}
+	return false
+}
+
+func sum(incomes []model.IncomeDto) string {
+	var total model.Money
+
+	for _, income := range incomes {
+		total.Decimal = total.Add(income.Sum.Decimal)
+	}
+
+	return total.String()
+}
+
+// hasIncomeOfGroup reports whether the March rent belongs to the group only.
+func hasIncomeOfGroup(incomes []model.IncomeDto, group string) bool {
+	for _, income := range incomes {
+		if income.Name == "Rent" && len(income.Groups) == 1 && income.Groups[0].Name == group && income.Date.Month() == time.March {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/config"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	NoAuth     = "none"
+	BearerAuth = "bearer"
+	APIKeyAuth = "api-key"
+	BasicAuth  = "basic"
+	OAuth2Auth = "oauth2"
+)
+
+// defaultTokenTTL is the lifetime of an access token without expires_in.
+const defaultTokenTTL = 5 * time.Minute
+
+// Authenticator adds the credentials to every request to HOB.
+type Authenticator interface {
+	Authenticate(request *http.Request) error
+}
+
+// invalidator is an authenticator with credentials that can be renewed, the credentials of a request that HOB rejected
+// with 401 are invalidated, so the next request gets new ones.
+type invalidator interface {
+	Invalidate(request *http.Request)
+}
+
+type noAuthenticator struct{}
+
+func (n noAuthenticator) Authenticate(request *http.Request) error {
+	return nil
+}
+
+type bearerAuthenticator struct {
+	token string
+}
+
+func (b bearerAuthenticator) Authenticate(request *http.Request) error {
+	request.Header.Set("Authorization", "Bearer "+b.token)
+	return nil
+}
+
+type apiKeyAuthenticator struct {
+	header string
+	key    string
+}
+
+func (a apiKeyAuthenticator) Authenticate(request *http.Request) error {
+	request.Header.Set(a.header, a.key)
+	return nil
+}
+
+type basicAuthenticator struct {
+	username string
+	password string
+}
+
+func (b basicAuthenticator) Authenticate(request *http.Request) error {
+	request.SetBasicAuth(b.username, b.password)
+	return nil
+}
+
+// oauth2Authenticator gets an access token with the client credentials grant and reuses it until it expires or HOB
+// rejects it. A token without expires_in is reused for defaultTokenTTL.
+type oauth2Authenticator struct {
+	tokenURL     string
+	clientId     string
+	clientSecret string
+	scopes       []string
+	httpClient   *http.Client
+	mutex        sync.Mutex
+	token        string
+	expiry       time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func (o *oauth2Authenticator) Authenticate(request *http.Request) error {
+	token, err := o.accessToken(request.Context())
+
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Invalidate drops the token of the request, unless it was already renewed by another request.
+func (o *oauth2Authenticator) Invalidate(request *http.Request) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.token != "" && request.Header.Get("Authorization") == "Bearer "+o.token {
+		o.token = ""
+	}
+}
+
+func (o *oauth2Authenticator) accessToken(ctx context.Context) (string, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	// the token is renewed a bit earlier, so it does not expire during the request
+	if o.token != "" && time.Now().Add(30*time.Second).Before(o.expiry) {
+		return o.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(o.scopes) != 0 {
+		form.Set("scope", strings.Join(o.scopes, " "))
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, o.tokenURL, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return "", err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth(url.QueryEscape(o.clientId), url.QueryEscape(o.clientSecret))
+
+	response, err := o.httpClient.Do(request)
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get access token from %s", o.tokenURL)
+	}
+
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+
+	if err != nil {
+		return "", err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return "", errors.Wrapf(&ResponseError{StatusCode: response.StatusCode, Body: string(body)}, "failed to get access token from %s", o.tokenURL)
+	}
+
+	var token tokenResponse
+
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", errors.Wrapf(err, "invalid access token response from %s", o.tokenURL)
+	}
+
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("access token response from %s has no access_token", o.tokenURL)
+	}
+
+	ttl := defaultTokenTTL
+
+	if token.ExpiresIn > 0 {
+		ttl = time.Duration(token.ExpiresIn) * time.Second
+	}
+
+	o.token = token.AccessToken
+	o.expiry = time.Now().Add(ttl)
+
+	return o.token, nil
+}
+
+// NewAuthenticator creates the authenticator of the auth config. The values that are not set by the flags are read
+// from the environment variables and then from the credentials file. If the auth type is not set, it is selected
+// by the credentials that are set.
+func NewAuthenticator(auth config.AuthConfig, httpClient *http.Client) (Authenticator, error) {
+	auth, err := resolveAuth(auth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch auth.Type {
+	case NoAuth:
+		return noAuthenticator{}, nil
+	case BearerAuth:
+		if auth.Token == "" {
+			return nil, errors.New("bearer auth requires a token")
+		}
+		return bearerAuthenticator{token: auth.Token}, nil
+	case APIKeyAuth:
+		if auth.APIKey == "" {
+			return nil, errors.New("api key auth requires an api key")
+		}
+		return apiKeyAuthenticator{header: auth.APIKeyHeader, key: auth.APIKey}, nil
+	case BasicAuth:
+		if auth.Username == "" {
+			return nil, errors.New("basic auth requires a username")
+		}
+		return basicAuthenticator{username: auth.Username, password: auth.Password}, nil
+	case OAuth2Auth:
+		if auth.TokenURL == "" || auth.ClientId == "" || auth.ClientSecret == "" {
+			return nil, errors.New("oauth2 auth requires a token url, a client id and a client secret")
+		}
+		return &oauth2Authenticator{
+			tokenURL:     auth.TokenURL,
+			clientId:     auth.ClientId,
+			clientSecret: auth.ClientSecret,
+			scopes:       auth.Scopes,
+			httpClient:   httpClient,
+		}, nil
+	default:
+		return nil, fmt.Errorf("auth %s is not supported", auth.Type)
+	}
+}
+
+// credentialsFile is the JSON file with the credentials of HOB.
+type credentialsFile struct {
+	Auth         string   `json:"auth"`
+	Token        string   `json:"token"`
+	APIKey       string   `json:"apiKey"`
+	APIKeyHeader string   `json:"apiKeyHeader"`
+	Username     string   `json:"username"`
+	Password     string   `json:"password"`
+	ClientId     string   `json:"clientId"`
+	ClientSecret string   `json:"clientSecret"`
+	TokenURL     string   `json:"tokenUrl"`
+	Scopes       []string `json:"scopes"`
+}
+
+func resolveAuth(auth config.AuthConfig) (config.AuthConfig, error) {
+	fromEnv := func(value *string, name string) {
+		if *value == "" {
+			*value = os.Getenv(name)
+		}
+	}
+
+	fromEnv(&auth.CredentialsFile, "HOB_CREDENTIALS_FILE")
+	fromEnv(&auth.Type, "HOB_AUTH")
+	fromEnv(&auth.Token, "HOB_TOKEN")
+	fromEnv(&auth.APIKey, "HOB_API_KEY")
+	fromEnv(&auth.APIKeyHeader, "HOB_API_KEY_HEADER")
+	fromEnv(&auth.Username, "HOB_USERNAME")
+	fromEnv(&auth.Password, "HOB_PASSWORD")
+	fromEnv(&auth.ClientId, "HOB_CLIENT_ID")
+	fromEnv(&auth.ClientSecret, "HOB_CLIENT_SECRET")
+	fromEnv(&auth.TokenURL, "HOB_TOKEN_URL")
+
+	if len(auth.Scopes) == 0 && os.Getenv("HOB_SCOPES") != "" {
+		auth.Scopes = strings.Fields(strings.ReplaceAll(os.Getenv("HOB_SCOPES"), ",", " "))
+	}
+
+	if auth.CredentialsFile != "" {
+		fileBytes, err := ioutil.ReadFile(auth.CredentialsFile)
+
+		if err != nil {
+			return auth, errors.Wrapf(err, "failed to read credentials file %s", auth.CredentialsFile)
+		}
+
+		var credentials credentialsFile
+
+		if err := json.Unmarshal(fileBytes, &credentials); err != nil {
+			return auth, errors.Wrapf(err, "failed to unmarshal credentials file %s", auth.CredentialsFile)
+		}
+
+		fromFile := func(value *string, fileValue string) {
+			if *value == "" {
+				*value = fileValue
+			}
+		}
+
+		fromFile(&auth.Type, credentials.Auth)
+		fromFile(&auth.Token, credentials.Token)
+		fromFile(&auth.APIKey, credentials.APIKey)
+		fromFile(&auth.APIKeyHeader, credentials.APIKeyHeader)
+		fromFile(&auth.Username, credentials.Username)
+		fromFile(&auth.Password, credentials.Password)
+		fromFile(&auth.ClientId, credentials.ClientId)
+		fromFile(&auth.ClientSecret, credentials.ClientSecret)
+		fromFile(&auth.TokenURL, credentials.TokenURL)
+
+		if len(auth.Scopes) == 0 {
+			auth.Scopes = credentials.Scopes
+		}
+	}
+
+	if auth.APIKeyHeader == "" {
+		auth.APIKeyHeader = "X-API-Key"
+	}
+
+	if auth.Type == "" {
+		switch {
+		case auth.Token != "":
+			auth.Type = BearerAuth
+		case auth.APIKey != "":
+			auth.Type = APIKeyAuth
+		case auth.Username != "":
+			auth.Type = BasicAuth
+		case auth.ClientId != "":
+			auth.Type = OAuth2Auth
+		default:
+			auth.Type = NoAuth
+		}
+	}
+
+	return auth, nil
+}
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/config"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// clearAuthEnv unsets the environment variables of the auth, so the credentials of the environment are not used.
+func clearAuthEnv(t *testing.T) {
+	for _, name := range []string{"HOB_CREDENTIALS_FILE", "HOB_AUTH", "HOB_TOKEN", "HOB_API_KEY", "HOB_API_KEY_HEADER", "HOB_USERNAME", "HOB_PASSWORD", "HOB_CLIENT_ID", "HOB_CLIENT_SECRET", "HOB_TOKEN_URL", "HOB_SCOPES"} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestAuthenticatorSetsCredentials(t *testing.T) {
+	clearAuthEnv(t)
+
+	tests := []struct {
+		auth   config.AuthConfig
+		header string
+		value  string
+	}{
+		{auth: config.AuthConfig{Token: "token"}, header: "Authorization", value: "Bearer token"},
+		{auth: config.AuthConfig{APIKey: "key"}, header: "X-API-Key", value: "key"},
+		{auth: config.AuthConfig{APIKey: "key", APIKeyHeader: "X-Key"}, header: "X-Key", value: "key"},
+		{auth: config.AuthConfig{Username: "user", Password: "secret"}, header: "Authorization", value: "Basic dXNlcjpzZWNyZXQ="},
+		{auth: config.AuthConfig{}, header: "Authorization", value: ""},
+	}
+
+	for _, test := range tests {
+		authenticator, err := NewAuthenticator(test.auth, http.DefaultClient)
+
+		if err != nil {
+			t.Fatalf("%+v: %v", test.auth, err)
+		}
+
+		request := httptest.NewRequest(http.MethodGet, "http://hob/api/v1/health", nil)
+
+		if err := authenticator.Authenticate(request); err != nil {
+			t.Fatalf("%+v: %v", test.auth, err)
+		}
+
+		if value := request.Header.Get(test.header); value != test.value {
+			t.Errorf("%+v: expected %s %q, got %q", test.auth, test.header, test.value, value)
+		}
+	}
+}
+
+func TestAuthenticatorRejectsMissingCredentials(t *testing.T) {
+	clearAuthEnv(t)
+
+	for _, auth := range []config.AuthConfig{
+		{Type: BearerAuth},
+		{Type: APIKeyAuth},
+		{Type: BasicAuth},
+		{Type: OAuth2Auth, ClientId: "client"},
+		{Type: "digest"},
+	} {
+		if _, err := NewAuthenticator(auth, http.DefaultClient); err == nil {
+			t.Errorf("%+v: expected an error", auth)
+		}
+	}
+}
+
+// newTokenServer returns the token endpoint that issues the tokens token-1, token-2 and so on with the expires_in,
+// the number of the issued tokens is counted.
+func newTokenServer(t *testing.T, expiresIn int, issued *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if id, secret, _ := request.BasicAuth(); id != "client" || secret != "secret" {
+			http.Error(writer, "invalid client", http.StatusUnauthorized)
+			return
+		}
+
+		count := atomic.AddInt32(issued, 1)
+
+		writer.Header().Set("Content-Type", "application/json")
+
+		if expiresIn > 0 {
+			fmt.Fprintf(writer, `{"access_token": "token-%d", "token_type": "bearer", "expires_in": %d}`, count, expiresIn)
+		} else {
+			fmt.Fprintf(writer, `{"access_token": "token-%d", "token_type": "bearer"}`, count)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newOAuth2Authenticator(t *testing.T, tokenURL string) *oauth2Authenticator {
+	authenticator, err := NewAuthenticator(config.AuthConfig{
+		Type:         OAuth2Auth,
+		TokenURL:     tokenURL,
+		ClientId:     "client",
+		ClientSecret: "secret",
+	}, http.DefaultClient)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return authenticator.(*oauth2Authenticator)
+}
+
+func authorization(t *testing.T, authenticator Authenticator) string {
+	request := httptest.NewRequest(http.MethodGet, "http://hob/api/v1/health", nil)
+
+	if err := authenticator.Authenticate(request); err != nil {
+		t.Fatal(err)
+	}
+
+	return request.Header.Get("Authorization")
+}
+
+func TestOAuth2TokenIsReusedUntilItExpires(t *testing.T) {
+	var issued int32
+	authenticator := newOAuth2Authenticator(t, newTokenServer(t, 3600, &issued).URL)
+
+	if first, second := authorization(t, authenticator), authorization(t, authenticator); first != "Bearer token-1" || second != first {
+		t.Errorf("expected the token reused, got %s and %s", first, second)
+	}
+
+	authenticator.expiry = time.Now()
+
+	if token := authorization(t, authenticator); token != "Bearer token-2" || issued != 2 {
+		t.Errorf("expected the expired token renewed, got %s of %d tokens", token, issued)
+	}
+}
+
+func TestOAuth2TokenWithoutExpiryExpires(t *testing.T) {
+	var issued int32
+	authenticator := newOAuth2Authenticator(t, newTokenServer(t, 0, &issued).URL)
+
+	authorization(t, authenticator)
+
+	if expiry := time.Until(authenticator.expiry); expiry <= 0 || expiry > defaultTokenTTL {
+		t.Errorf("expected the token to expire within %s, expires in %s", defaultTokenTTL, expiry)
+	}
+}
+
+func TestOAuth2TokenRequestIsCancelled(t *testing.T) {
+	var issued int32
+	authenticator := newOAuth2Authenticator(t, newTokenServer(t, 3600, &issued).URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	request := httptest.NewRequest(http.MethodGet, "http://hob/api/v1/health", nil).WithContext(ctx)
+
+	if err := authenticator.Authenticate(request); err == nil || issued != 0 {
+		t.Errorf("expected the token request cancelled, got %v of %d tokens", err, issued)
+	}
+}
+
+func TestUnauthorizedRequestRenewsToken(t *testing.T) {
+	var issued, requests int32
+	tokenServer := newTokenServer(t, 3600, &issued)
+
+	// HOB revoked the first token before its expiry
+	hob := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		if request.Header.Get("Authorization") != "Bearer token-2" {
+			http.Error(writer, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		writer.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(hob.Close)
+
+	hobClient, err := NewHobClient(&config.CMDConfig{
+		HobURL: hob.URL,
+		Auth:   config.AuthConfig{Type: OAuth2Auth, TokenURL: tokenServer.URL, ClientId: "client", ClientSecret: "secret"},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hobClient.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("expected the request with the renewed token to succeed, got %v", err)
+	}
+
+	if issued != 2 || requests != 2 {
+		t.Errorf("expected 2 tokens and 2 requests, got %d and %d", issued, requests)
+	}
+}
+
+func TestUnauthorizedRequestIsRenewedOnce(t *testing.T) {
+	var issued, requests int32
+	tokenServer := newTokenServer(t, 3600, &issued)
+
+	hob := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Error(writer, "unauthorized", http.StatusUnauthorized)
+	}))
+	t.Cleanup(hob.Close)
+
+	hobClient, err := NewHobClient(&config.CMDConfig{
+		HobURL:     hob.URL,
+		MaxRetries: 3,
+		Auth:       config.AuthConfig{Type: OAuth2Auth, TokenURL: tokenServer.URL, ClientId: "client", ClientSecret: "secret"},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hobClient.HealthCheck(context.Background()); err == nil {
+		t.Fatal("expected the request to fail")
+	}
+
+	if issued != 2 || requests != 2 {
+		t.Errorf("expected 2 tokens and 2 requests, got %d and %d", issued, requests)
+	}
+}
@@ -1,34 +1,65 @@
 package client
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/VlasovArtem/hob-migration/src/config"
 	"github.com/VlasovArtem/hob-migration/src/model"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"time"
 )
 
 type HobClient struct {
-	config *config.CMDConfig
+	config        *config.CMDConfig
+	httpClient    *http.Client
+	authenticator Authenticator
 }
 
-func NewHobClient(config *config.CMDConfig) *HobClient {
+func NewHobClient(config *config.CMDConfig) (*HobClient, error) {
+	httpClient := newHTTPClient(config)
+
+	authenticator, err := NewAuthenticator(config.Auth, httpClient)
+
+	if err != nil {
+		return nil, err
+	}
+
 	return &HobClient{
-		config: config,
+		config:        config,
+		httpClient:    httpClient,
+		authenticator: authenticator,
+	}, nil
+}
+
+func newHTTPClient(config *config.CMDConfig) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   config.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = config.ConnectTimeout
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   config.RequestTimeout,
 	}
 }
 
-func (h *HobClient) HealthCheck() error {
-	get, err := http.Get(h.config.HobURL + "/api/v1/health")
+func (h *HobClient) HealthCheck(ctx context.Context) error {
+	get, err := h.get(ctx, h.config.HobURL+"/api/v1/health")
 
 	if err != nil {
 		return err
 	}
 
+	defer get.Body.Close()
+
 	if get.StatusCode != 200 {
 		return errors.New("hob server is not available")
 	}
@@ -36,69 +67,124 @@ func (h *HobClient) HealthCheck() error {
 	return nil
 }
 
-func (h *HobClient) CreateHouse(request model.CreateHouseRequest) (model.HouseDto, error) {
+func (h *HobClient) CreateHouse(ctx context.Context, request model.CreateHouseRequest) (model.HouseDto, error) {
 	requestBytes, err := json.Marshal(request)
 
 	if err != nil {
 		return model.HouseDto{}, err
 	}
 
-	return ReadBody[model.HouseDto](http.Post(h.config.HobURL+"/api/v1/houses", "application/json", bytes.NewReader(requestBytes)))
+	return ReadBody[model.HouseDto](h.post(ctx, h.config.HobURL+"/api/v1/houses", requestBytes))
 }
 
-func (h *HobClient) CreateGroupBatch(request model.CreateGroupBatchRequest) ([]model.GroupDto, error) {
+func (h *HobClient) CreateHouseBatch(ctx context.Context, request model.CreateHouseBatchRequest) ([]model.HouseDto, error) {
+	requestBytes, err := json.Marshal(request)
+
+	if err != nil {
+		return []model.HouseDto{}, err
+	}
+
+	return ReadBody[[]model.HouseDto](h.post(ctx, h.config.HobURL+"/api/v1/houses/batch", requestBytes))
+}
+
+func (h *HobClient) CreateGroupBatch(ctx context.Context, request model.CreateGroupBatchRequest) ([]model.GroupDto, error) {
 	requestBytes, err := json.Marshal(request)
 
 	if err != nil {
 		return []model.GroupDto{}, err
 	}
 
-	return ReadBody[[]model.GroupDto](http.Post(h.config.HobURL+"/api/v1/groups/batch", "application/json", bytes.NewReader(requestBytes)))
+	return ReadBody[[]model.GroupDto](h.post(ctx, h.config.HobURL+"/api/v1/groups/batch", requestBytes))
 }
 
-func (h *HobClient) CreateIncomeBatch(request model.CreateIncomeBatchRequest) ([]model.IncomeDto, error) {
+func (h *HobClient) CreateIncomeBatch(ctx context.Context, request model.CreateIncomeBatchRequest) ([]model.IncomeDto, error) {
 	requestBytes, err := json.Marshal(request)
 
 	if err != nil {
 		return []model.IncomeDto{}, err
 	}
 
-	return ReadBody[[]model.IncomeDto](http.Post(h.config.HobURL+"/api/v1/incomes/batch", "application/json", bytes.NewReader(requestBytes)))
+	return ReadBody[[]model.IncomeDto](h.post(ctx, h.config.HobURL+"/api/v1/incomes/batch", requestBytes))
 }
 
-func (h *HobClient) CreatePaymentBatch(request model.CreatePaymentBatchRequest) ([]model.PaymentDto, error) {
+func (h *HobClient) CreateProviderBatch(ctx context.Context, request model.CreateProviderBatchRequest) ([]model.ProviderDto, error) {
+	requestBytes, err := json.Marshal(request)
+
+	if err != nil {
+		return []model.ProviderDto{}, err
+	}
+
+	return ReadBody[[]model.ProviderDto](h.post(ctx, h.config.HobURL+"/api/v1/providers/batch", requestBytes))
+}
+
+func (h *HobClient) CreatePaymentBatch(ctx context.Context, request model.CreatePaymentBatchRequest) ([]model.PaymentDto, error) {
 	requestBytes, err := json.Marshal(request)
 
 	if err != nil {
 		return []model.PaymentDto{}, err
 	}
 
-	return ReadBody[[]model.PaymentDto](http.Post(h.config.HobURL+"/api/v1/payments/batch", "application/json", bytes.NewReader(requestBytes)))
+	return ReadBody[[]model.PaymentDto](h.post(ctx, h.config.HobURL+"/api/v1/payments/batch", requestBytes))
+}
+
+func (h *HobClient) GetGroupsByUserId(ctx context.Context, userId string) ([]model.GroupDto, error) {
+	return ReadBody[[]model.GroupDto](h.get(ctx, h.config.HobURL+"/api/v1/groups/user/"+userId))
 }
 
-func (h *HobClient) DeleteGroupById(id uuid.UUID) error {
-	return deleteByURL(h.config.HobURL + "/api/v1/groups/" + id.String())
+func (h *HobClient) GetHousesByUserId(ctx context.Context, userId string) ([]model.HouseDto, error) {
+	return ReadBody[[]model.HouseDto](h.get(ctx, h.config.HobURL+"/api/v1/houses/user/"+userId))
 }
 
-func (h *HobClient) DeleteHouseById(id uuid.UUID) error {
-	return deleteByURL(h.config.HobURL + "/api/v1/houses/" + id.String())
+func (h *HobClient) GetProvidersByUserId(ctx context.Context, userId string) ([]model.ProviderDto, error) {
+	return ReadBody[[]model.ProviderDto](h.get(ctx, h.config.HobURL+"/api/v1/providers/user/"+userId))
 }
 
-func (h *HobClient) DeleteIncomeById(id uuid.UUID) error {
-	return deleteByURL(h.config.HobURL + "/api/v1/incomes/" + id.String())
+func (h *HobClient) GetIncomesByHouseId(ctx context.Context, id uuid.UUID) ([]model.IncomeDto, error) {
+	return ReadBody[[]model.IncomeDto](h.get(ctx, h.config.HobURL+"/api/v1/incomes/house/"+id.String()))
 }
 
-func (h *HobClient) DeletePaymentById(id uuid.UUID) error {
-	return deleteByURL(h.config.HobURL + "/api/v1/payments/" + id.String())
+func (h *HobClient) GetIncomesByGroupId(ctx context.Context, id uuid.UUID) ([]model.IncomeDto, error) {
+	return ReadBody[[]model.IncomeDto](h.get(ctx, h.config.HobURL+"/api/v1/incomes/group/"+id.String()))
 }
 
-func (h *HobClient) UserExists(id string) bool {
-	response, err := http.Get(h.config.HobURL + "/api/v1/users/" + id)
+func (h *HobClient) GetPaymentsByHouseId(ctx context.Context, id uuid.UUID) ([]model.PaymentDto, error) {
+	return ReadBody[[]model.PaymentDto](h.get(ctx, h.config.HobURL+"/api/v1/payments/house/"+id.String()))
+}
+
+func (h *HobClient) GetPaymentsByGroupId(ctx context.Context, id uuid.UUID) ([]model.PaymentDto, error) {
+	return ReadBody[[]model.PaymentDto](h.get(ctx, h.config.HobURL+"/api/v1/payments/group/"+id.String()))
+}
+
+func (h *HobClient) DeleteGroupById(ctx context.Context, id uuid.UUID) error {
+	return h.delete(ctx, h.config.HobURL+"/api/v1/groups/"+id.String())
+}
+
+func (h *HobClient) DeleteHouseById(ctx context.Context, id uuid.UUID) error {
+	return h.delete(ctx, h.config.HobURL+"/api/v1/houses/"+id.String())
+}
+
+func (h *HobClient) DeleteProviderById(ctx context.Context, id uuid.UUID) error {
+	return h.delete(ctx, h.config.HobURL+"/api/v1/providers/"+id.String())
+}
+
+func (h *HobClient) DeleteIncomeById(ctx context.Context, id uuid.UUID) error {
+	return h.delete(ctx, h.config.HobURL+"/api/v1/incomes/"+id.String())
+}
+
+func (h *HobClient) DeletePaymentById(ctx context.Context, id uuid.UUID) error {
+	return h.delete(ctx, h.config.HobURL+"/api/v1/payments/"+id.String())
+}
+
+func (h *HobClient) UserExists(ctx context.Context, id string) bool {
+	response, err := h.get(ctx, h.config.HobURL+"/api/v1/users/"+id)
 
 	if err != nil {
-		log.Error().Err(err)
+		log.Error().Err(err).Msgf("Failed to get user with id %s", id)
 		return false
 	}
+
+	defer response.Body.Close()
+
 	if response.StatusCode != 200 {
 		return false
 	}
@@ -123,8 +209,8 @@ func ReadBody[T any](response *http.Response, err error) (T, error) {
 
 	if response.StatusCode != 200 && response.StatusCode != 201 {
 		text := string(allBytes)
-		log.Err(err).Msg(text)
-		return t, errors.New(text)
+		log.Error().Msgf("%s %s responded with status %d: %s", response.Request.Method, response.Request.URL, response.StatusCode, text)
+		return t, &ResponseError{StatusCode: response.StatusCode, Body: text}
 	}
 
 	err = json.Unmarshal(allBytes, &t)
@@ -136,21 +222,51 @@ func ReadBody[T any](response *http.Response, err error) (T, error) {
 	return t, nil
 }
 
-func deleteByURL(url string) error {
-	request, err := http.NewRequest(http.MethodDelete, url, nil)
+// ResponseError is returned for a response of HOB with an unexpected status.
+type ResponseError struct {
+	StatusCode int
+	Body       string
+}
 
-	if err != nil {
-		return err
+func (r *ResponseError) Error() string {
+	if r.Body == "" {
+		return fmt.Sprintf("hob responded with status %d", r.StatusCode)
 	}
+	return fmt.Sprintf("hob responded with status %d: %s", r.StatusCode, r.Body)
+}
 
-	response, err := http.DefaultClient.Do(request)
+// IsStatus reports whether the error is a response of HOB with the status.
+func IsStatus(err error, statusCode int) bool {
+	var responseError *ResponseError
+	return errors.As(err, &responseError) && responseError.StatusCode == statusCode
+}
+
+// IsTimeout reports whether the request failed because HOB did not respond in time.
+func IsTimeout(err error) bool {
+	var netError net.Error
+	return errors.As(err, &netError) && netError.Timeout()
+}
+
+func (h *HobClient) get(ctx context.Context, url string) (*http.Response, error) {
+	return h.do(ctx, http.MethodGet, url, nil)
+}
+
+func (h *HobClient) post(ctx context.Context, url string, body []byte) (*http.Response, error) {
+	return h.do(ctx, http.MethodPost, url, body)
+}
+
+func (h *HobClient) delete(ctx context.Context, url string) error {
+	response, err := h.do(ctx, http.MethodDelete, url, nil)
 
 	if err != nil {
 		return err
 	}
 
+	defer response.Body.Close()
+
 	if response.StatusCode != 204 {
-		return errors.New("failed to delete")
+		body, _ := ioutil.ReadAll(response.Body)
+		return &ResponseError{StatusCode: response.StatusCode, Body: string(body)}
 	}
 
 	return nil
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"github.com/rs/zerolog/log"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// do sends the request and retries it with a jittered exponential backoff. Responses with the status 429 or 5xx and
+// network errors are retried for idempotent methods. A POST is retried only on 429, 502 and 503, because after a
+// network error, a 500 or a 504 the entities could already be created.
+// A cancelled context stops the retries, but a POST that was sent is completed, so the created entities are known
+// and can be rolled back. A request rejected with 401 is sent once more with renewed credentials, if the
+// authenticator can renew them.
+func (h *HobClient) do(ctx context.Context, method string, url string, body []byte) (*http.Response, error) {
+	requestCtx := ctx
+	if method == http.MethodPost {
+		requestCtx = detachedContext{ctx}
+	}
+
+	reauthenticated := false
+
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var bodyReader io.Reader
+		if body != nil {
+			bodyReader = bytes.NewReader(body)
+		}
+
+		request, err := http.NewRequestWithContext(requestCtx, method, url, bodyReader)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if body != nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+
+		if err := h.authenticator.Authenticate(request); err != nil {
+			return nil, err
+		}
+
+		response, err := h.httpClient.Do(request)
+
+		if err == nil && response.StatusCode == http.StatusUnauthorized && !reauthenticated {
+			if invalidator, ok := h.authenticator.(invalidator); ok {
+				log.Warn().Msgf("%s %s responded with status 401, renewing the credentials", method, url)
+
+				io.Copy(io.Discard, response.Body)
+				response.Body.Close()
+
+				invalidator.Invalidate(request)
+				reauthenticated = true
+				// the renewal is not a retry
+				attempt--
+				continue
+			}
+		}
+
+		if attempt >= h.config.MaxRetries || !retryable(method, response, err) {
+			return response, err
+		}
+
+		delay := h.backoff(attempt)
+
+		if err != nil {
+			log.Warn().Err(err).Msgf("%s %s failed, retry %d of %d in %s", method, url, attempt+1, h.config.MaxRetries, delay)
+		} else {
+			if retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After")); ok {
+				delay = retryAfter
+			}
+
+			log.Warn().Msgf("%s %s responded with status %d, retry %d of %d in %s", method, url, response.StatusCode, attempt+1, h.config.MaxRetries, delay)
+
+			// the body is drained, so the connection can be reused
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// detachedContext keeps the values of the context without its cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+func (d detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (d detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (d detachedContext) Err() error {
+	return nil
+}
+
+func retryable(method string, response *http.Response, err error) bool {
+	if method == http.MethodPost {
+		if err != nil {
+			return false
+		}
+
+		switch response.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+			return true
+		}
+
+		return false
+	}
+
+	return err != nil || response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+}
+
+// backoff returns the delay before the retry, the exponential delay capped by the max delay with a random jitter
+// of up to the half of the delay.
+func (h *HobClient) backoff(attempt int) time.Duration {
+	delay := h.config.RetryDelay
+
+	for i := 0; i < attempt && delay < h.config.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > h.config.RetryMaxDelay {
+		delay = h.config.RetryMaxDelay
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// parseRetryAfter reads the Retry-After header as the number of seconds or as the date of the retry.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
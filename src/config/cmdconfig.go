@@ -3,29 +3,181 @@ package config
 import (
 	"fmt"
 	"github.com/spf13/pflag"
+	"path/filepath"
+	"time"
+)
+
+const (
+	MigrateCommand  = "migrate"
+	RollbackCommand = "rollback"
+	ExportCommand   = "export"
 )
 
 type CMDConfig struct {
+	Command          string
 	HobURL           string
 	MigratorFilePath string
 	UserId           string
+	DryRun           bool
+	RunId            string
+	JournalDir       string
+	JournalPath      string
+	Resume           string
+	ReuseExisting    bool
+	KeepOnInterrupt  bool
+	KeepOnFailure    bool
+	ErrorsFilePath   string
+	OutputDir        string
+	ReportPath       string
+	ReportMarkdown   string
+	ConnectTimeout   time.Duration
+	RequestTimeout   time.Duration
+	MaxRetries       int
+	RetryDelay       time.Duration
+	RetryMaxDelay    time.Duration
+	BatchSize        int
+	Concurrency      int
+	Auth             AuthConfig
+}
+
+// AuthConfig is the authentication of the requests to HOB. The values that are not set by the flags are read from
+// the environment variables and the credentials file.
+type AuthConfig struct {
+	Type            string
+	Token           string
+	APIKey          string
+	APIKeyHeader    string
+	Username        string
+	Password        string
+	ClientId        string
+	ClientSecret    string
+	TokenURL        string
+	Scopes          []string
+	CredentialsFile string
 }
 
 func NewCMDConfig() *CMDConfig {
 	return &CMDConfig{}
 }
 
+// Default returns the configuration with the defaults of the flags, the base of the configuration of the library.
+func Default() CMDConfig {
+	return CMDConfig{
+		Command:        MigrateCommand,
+		HobURL:         "http://localhost:3030",
+		JournalDir:     ".",
+		ReuseExisting:  true,
+		ConnectTimeout: 10 * time.Second,
+		RequestTimeout: 2 * time.Minute,
+		MaxRetries:     3,
+		RetryDelay:     500 * time.Millisecond,
+		RetryMaxDelay:  30 * time.Second,
+		BatchSize:      500,
+		Concurrency:    4,
+	}
+}
+
+// WithDefaults returns the configuration with the defaults of the empty command, URL and journal directory, and of
+// the zero timeouts, delays, batch size and concurrency. The retries and the reuse of the existing entities are not
+// changed, because their zero values disable them.
+func (c CMDConfig) WithDefaults() CMDConfig {
+	defaults := Default()
+
+	if c.Command == "" {
+		c.Command = defaults.Command
+	}
+	if c.HobURL == "" {
+		c.HobURL = defaults.HobURL
+	}
+	if c.JournalDir == "" {
+		c.JournalDir = defaults.JournalDir
+	}
+	if c.ConnectTimeout <= 0 {
+		c.ConnectTimeout = defaults.ConnectTimeout
+	}
+	if c.RequestTimeout <= 0 {
+		c.RequestTimeout = defaults.RequestTimeout
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = defaults.RetryDelay
+	}
+	if c.RetryMaxDelay <= 0 {
+		c.RetryMaxDelay = defaults.RetryMaxDelay
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaults.BatchSize
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = defaults.Concurrency
+	}
+
+	return c
+}
+
 func (c *CMDConfig) Parse() {
-	pflag.StringVarP(&c.HobURL, "url", "u", "http://localhost:3030", "URL to HOB application.")
+	defaults := Default()
+
+	pflag.StringVarP(&c.HobURL, "url", "u", defaults.HobURL, "URL to HOB application.")
 	pflag.StringVarP(&c.MigratorFilePath, "migrator-path", "m", "", fmt.Sprintf("Path to the migrator file path. Details:\n%s)", migrationDetails()))
 	pflag.StringVarP(&c.UserId, "user-id", "i", "", "User id")
+	pflag.BoolVar(&c.DryRun, "dry-run", false, "Validate and resolve all files without creating any data in HOB.")
+	pflag.StringVar(&c.JournalDir, "journal-dir", defaults.JournalDir, "Directory of the journal with the created entities of the migration.")
+	pflag.StringVarP(&c.JournalPath, "journal", "j", "", "Path to the journal to rollback (rollback command only).")
+	pflag.StringVar(&c.Resume, "resume", "", "Run id of the interrupted or failed migration to resume.")
+	pflag.BoolVar(&c.KeepOnInterrupt, "keep-on-interrupt", false, "Keep the entities created before the migration was interrupted instead of rolling them back.")
+	pflag.BoolVar(&c.KeepOnFailure, "keep-on-failure", false, "Keep the entities created before the migration failed instead of rolling them back, a resumed migration always keeps them.")
+	pflag.StringVar(&c.ErrorsFilePath, "errors-file", "", "Path to the CSV or JSON file with the invalid rows of the migration files.")
+	pflag.StringVar(&c.ReportPath, "report", "", "Path to the JSON report of the migration.")
+	pflag.StringVar(&c.ReportMarkdown, "report-markdown", "", "Path to the Markdown report of the migration, written together with the JSON report.")
+	pflag.StringVarP(&c.OutputDir, "output-dir", "o", "", "Directory of the exported files (export command only).")
+	pflag.BoolVar(&c.ReuseExisting, "reuse-existing", defaults.ReuseExisting, "Reuse the groups, houses and providers of the user that already exist in HOB instead of creating them again.")
+	pflag.DurationVar(&c.ConnectTimeout, "connect-timeout", defaults.ConnectTimeout, "Timeout of the connection to HOB.")
+	pflag.DurationVar(&c.RequestTimeout, "request-timeout", defaults.RequestTimeout, "Timeout of a request to HOB, including the response body.")
+	pflag.IntVar(&c.MaxRetries, "max-retries", defaults.MaxRetries, "Number of retries of a failed request to HOB, 0 disables the retries.")
+	pflag.DurationVar(&c.RetryDelay, "retry-delay", defaults.RetryDelay, "Delay before the first retry, doubled for every next retry.")
+	pflag.DurationVar(&c.RetryMaxDelay, "retry-max-delay", defaults.RetryMaxDelay, "Max delay between the retries.")
+	pflag.IntVar(&c.BatchSize, "batch-size", defaults.BatchSize, "Max number of groups, houses, providers, incomes or payments of a batch request to HOB.")
+	pflag.IntVar(&c.Concurrency, "concurrency", defaults.Concurrency, "Max number of concurrent requests when the houses are created one by one.")
+	pflag.StringVar(&c.Auth.Type, "auth", "", "Authentication of HOB: none, bearer, api-key, basic or oauth2. Selected by the credentials if not set. Env: HOB_AUTH")
+	pflag.StringVar(&c.Auth.Token, "token", "", "Bearer token. Env: HOB_TOKEN")
+	pflag.StringVar(&c.Auth.APIKey, "api-key", "", "API key. Env: HOB_API_KEY")
+	pflag.StringVar(&c.Auth.APIKeyHeader, "api-key-header", "", "Header of the API key, X-API-Key if not set. Env: HOB_API_KEY_HEADER")
+	pflag.StringVar(&c.Auth.Username, "username", "", "Username of the basic auth. Env: HOB_USERNAME")
+	pflag.StringVar(&c.Auth.Password, "password", "", "Password of the basic auth. Env: HOB_PASSWORD")
+	pflag.StringVar(&c.Auth.ClientId, "client-id", "", "OAuth2 client id. Env: HOB_CLIENT_ID")
+	pflag.StringVar(&c.Auth.ClientSecret, "client-secret", "", "OAuth2 client secret. Env: HOB_CLIENT_SECRET")
+	pflag.StringVar(&c.Auth.TokenURL, "token-url", "", "OAuth2 token URL of the client credentials flow. Env: HOB_TOKEN_URL")
+	pflag.StringSliceVar(&c.Auth.Scopes, "scopes", nil, "OAuth2 scopes. Env: HOB_SCOPES")
+	pflag.StringVar(&c.Auth.CredentialsFile, "credentials-file", "", "Path to the JSON file with the credentials. Env: HOB_CREDENTIALS_FILE")
 	pflag.Parse()
+
+	c.Command = MigrateCommand
+	if args := pflag.Args(); len(args) > 0 {
+		c.Command = args[0]
+	}
+
+	c.RunId = c.Resume
+	if c.RunId == "" {
+		c.RunId = NewRunId()
+	}
+}
+
+// NewRunId returns the id of a new run, the time of the start of the run.
+func NewRunId() string {
+	return time.Now().Format("20060102-150405")
+}
+
+// MigrationJournalPath returns the path of the journal of the current run.
+func (c *CMDConfig) MigrationJournalPath() string {
+	return filepath.Join(c.JournalDir, c.RunId+".journal")
 }
 
 func migrationDetails() string {
-	return "Example of the migrator json:\n{\"groups\":\"path_to_the_file\"}\n\nPossible Values of keys:\n- groups\n- houses\n- incomes\n- payments"
+	return "Example of the migrator json (or yaml with the .yaml extension):\n{\"groups\":\"path_to_the_file\",\"incomes\":{\"path\":\"path_to_the_file\",\"house\":\"house_identifier\"}}\n\nPossible Values of keys:\n- groups\n- houses\n- providers\n- incomes\n- payments\n\nOptions of a file: path, format, delimiter, encoding, dateLayout, dateLayouts, timeZone, decimalSeparator, thousandsSeparator, currency, exchangeRates, batchSize, columns, house"
 }
 
 func (c *CMDConfig) String() string {
-	return fmt.Sprintf("HobURL: %s, MigratorFilePath: %s, UserId: %s", c.HobURL, c.MigratorFilePath, c.UserId)
+	return fmt.Sprintf("Command: %s, HobURL: %s, MigratorFilePath: %s, UserId: %s, DryRun: %t, RunId: %s, JournalDir: %s, JournalPath: %s, Resume: %s, ReuseExisting: %t, KeepOnInterrupt: %t, KeepOnFailure: %t, ErrorsFilePath: %s, OutputDir: %s, ReportPath: %s, ReportMarkdown: %s, ConnectTimeout: %s, RequestTimeout: %s, MaxRetries: %d, RetryDelay: %s, RetryMaxDelay: %s, BatchSize: %d, Concurrency: %d, Auth: %s",
+		c.Command, c.HobURL, c.MigratorFilePath, c.UserId, c.DryRun, c.RunId, c.JournalDir, c.JournalPath, c.Resume, c.ReuseExisting, c.KeepOnInterrupt, c.KeepOnFailure, c.ErrorsFilePath, c.OutputDir, c.ReportPath, c.ReportMarkdown,
+		c.ConnectTimeout, c.RequestTimeout, c.MaxRetries, c.RetryDelay, c.RetryMaxDelay, c.BatchSize, c.Concurrency, c.Auth.Type)
 }
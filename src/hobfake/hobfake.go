@@ -0,0 +1,695 @@
+// Package hobfake is an in-memory HOB server for the tests of the migration. It implements the endpoints used by
+// the HOB client and can inject faults into the responses.
+package hobfake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/model"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault changes the responses of the requests that match the method and the path prefix. An empty method or path
+// matches every request.
+type Fault struct {
+	Method string
+	Path   string
+	// Nth is the first matching request with the fault, starting from 1, every matching request has the fault if 0
+	Nth int
+	// Times is the number of the requests with the fault, unlimited if 0
+	Times int
+	// Delay is the delay of the response, the request is completed after the delay even if the client disconnected
+	Delay time.Duration
+	// StatusCode is the status of the response, the request is handled as usual after the delay if 0
+	StatusCode int
+	// Handled makes the server handle the request before it responds with the status, like a gateway timeout of a
+	// request that was processed
+	Handled bool
+	// Truncate makes the server skip the last entity of a batch request, like a HOB that responds with an incomplete
+	// batch, the entity is not created and is left out of the response
+	Truncate bool
+
+	matched int
+	applied int
+}
+
+// Server is the fake HOB server, the entities are kept in memory until the server is closed.
+type Server struct {
+	*httptest.Server
+
+	mutex        sync.Mutex
+	users        map[uuid.UUID]bool
+	groups       []model.GroupDto
+	houses       []model.HouseDto
+	providers    []model.ProviderDto
+	incomes      []model.IncomeDto
+	payments     []model.PaymentDto
+	faults       []*Fault
+	requests     []string
+	maxBatchSize int
+	disabled     map[string]bool
+	inFlight     int
+	maxInFlight  int
+}
+
+// NewServer starts the fake HOB server, the URL of the server is the HOB URL of the client.
+func NewServer() *Server {
+	s := &Server{users: make(map[uuid.UUID]bool)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// AddUser registers the user, so the migration of the user is accepted.
+func (s *Server) AddUser(id uuid.UUID) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.users[id] = true
+}
+
+// Inject adds the fault to the responses of the server.
+func (s *Server) Inject(fault Fault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.faults = append(s.faults, &fault)
+}
+
+// SetMaxBatchSize makes the server reject the batch requests with more entities with 413, no limit if 0.
+func (s *Server) SetMaxBatchSize(size int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.maxBatchSize = size
+}
+
+// DisableBatch makes the server respond to the batch requests of the entity type, e.g. houses, with 404, like a HOB
+// without the batch endpoint.
+func (s *Server) DisableBatch(entityType string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.disabled == nil {
+		s.disabled = make(map[string]bool)
+	}
+	s.disabled[entityType+"/batch"] = true
+}
+
+// MaxConcurrentRequests returns the max number of the requests that were handled by the server at the same time.
+func (s *Server) MaxConcurrentRequests() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.maxInFlight
+}
+
+// Requests returns the method and the path of every request received by the server.
+func (s *Server) Requests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string{}, s.requests...)
+}
+
+func (s *Server) Groups() []model.GroupDto {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]model.GroupDto{}, s.groups...)
+}
+
+func (s *Server) Houses() []model.HouseDto {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]model.HouseDto{}, s.houses...)
+}
+
+func (s *Server) Providers() []model.ProviderDto {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]model.ProviderDto{}, s.providers...)
+}
+
+func (s *Server) Incomes() []model.IncomeDto {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]model.IncomeDto{}, s.incomes...)
+}
+
+func (s *Server) Payments() []model.PaymentDto {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]model.PaymentDto{}, s.payments...)
+}
+
+// Empty reports whether the server has no groups, houses, providers, incomes and payments.
+func (s *Server) Empty() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.groups) == 0 && len(s.houses) == 0 && len(s.providers) == 0 && len(s.incomes) == 0 && len(s.payments) == 0
+}
+
+func (s *Server) serve(writer http.ResponseWriter, request *http.Request) {
+	s.mutex.Lock()
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		s.inFlight--
+		s.mutex.Unlock()
+	}()
+
+	fault := s.fault(request)
+
+	if fault != nil {
+		if fault.Delay > 0 {
+			// the body is read before the client can disconnect
+			body, _ := io.ReadAll(request.Body)
+			request.Body = io.NopCloser(bytes.NewReader(body))
+
+			time.Sleep(fault.Delay)
+		}
+
+		if fault.StatusCode != 0 {
+			if fault.Handled {
+				s.mutex.Lock()
+				s.handle(request)
+				s.mutex.Unlock()
+			}
+
+			http.Error(writer, "injected fault", fault.StatusCode)
+			return
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	status, body := s.handle(request)
+
+	if fault != nil && fault.Truncate && status < 400 {
+		body = s.truncate(request, body)
+	}
+
+	if status >= 400 {
+		http.Error(writer, fmt.Sprint(body), status)
+		return
+	}
+
+	if body == nil {
+		writer.WriteHeader(status)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(body)
+}
+
+// fault records the request and returns the fault of the request, nil if the request has no fault.
+func (s *Server) fault(request *http.Request) *Fault {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests = append(s.requests, request.Method+" "+request.URL.Path)
+
+	for _, fault := range s.faults {
+		if (fault.Method != "" && fault.Method != request.Method) || !strings.HasPrefix(request.URL.Path, fault.Path) {
+			continue
+		}
+
+		fault.matched++
+
+		if fault.matched < fault.Nth || (fault.Times != 0 && fault.applied >= fault.Times) {
+			continue
+		}
+
+		fault.applied++
+
+		copied := *fault
+		return &copied
+	}
+
+	return nil
+}
+
+// handle returns the status and the body of the request, the body of an error status is the message.
+func (s *Server) handle(request *http.Request) (int, any) {
+	path := strings.Split(strings.TrimPrefix(request.URL.Path, "/api/v1/"), "/")
+
+	switch request.Method {
+	case http.MethodGet:
+		return s.get(path)
+	case http.MethodPost:
+		return s.post(request, path)
+	case http.MethodDelete:
+		if len(path) != 2 {
+			return http.StatusNotFound, "not found"
+		}
+		return s.delete(path[0], path[1])
+	}
+
+	return http.StatusMethodNotAllowed, "method not allowed"
+}
+
+func (s *Server) get(path []string) (int, any) {
+	if len(path) == 1 && path[0] == "health" {
+		return http.StatusOK, map[string]string{"status": "ok"}
+	}
+
+	if len(path) == 2 && path[0] == "users" {
+		id, err := uuid.Parse(path[1])
+		if err != nil || !s.users[id] {
+			return http.StatusNotFound, "user not found"
+		}
+		return http.StatusOK, map[string]string{"id": id.String()}
+	}
+
+	if len(path) != 3 {
+		return http.StatusNotFound, "not found"
+	}
+
+	id, err := uuid.Parse(path[2])
+
+	if err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+
+	switch path[0] + "/" + path[1] {
+	case "groups/user":
+		return http.StatusOK, filter(s.groups, func(group model.GroupDto) bool { return group.OwnerId == id })
+	case "houses/user":
+		return http.StatusOK, filter(s.houses, func(house model.HouseDto) bool { return house.UserId == id })
+	case "providers/user":
+		return http.StatusOK, filter(s.providers, func(provider model.ProviderDto) bool { return provider.UserId == id })
+	case "incomes/house":
+		return http.StatusOK, filter(s.incomes, func(income model.IncomeDto) bool { return income.HouseId == id })
+	case "incomes/group":
+		return http.StatusOK, filter(s.incomes, func(income model.IncomeDto) bool { return hasGroup(income.Groups, id) })
+	case "payments/house":
+		return http.StatusOK, filter(s.payments, func(payment model.PaymentDto) bool { return payment.HouseId == id })
+	case "payments/group":
+		return http.StatusOK, filter(s.payments, func(payment model.PaymentDto) bool { return hasGroup(payment.Groups, id) })
+	}
+
+	return http.StatusNotFound, "not found"
+}
+
+func (s *Server) post(request *http.Request, path []string) (int, any) {
+	if s.disabled[strings.Join(path, "/")] {
+		return http.StatusNotFound, "not found"
+	}
+
+	switch strings.Join(path, "/") {
+	case "groups/batch":
+		var batch model.CreateGroupBatchRequest
+		if err := json.NewDecoder(request.Body).Decode(&batch); err != nil {
+			return http.StatusBadRequest, err.Error()
+		}
+		return createBatch(s, batch.Groups, s.newGroup, func(dto model.GroupDto) { s.groups = append(s.groups, dto) })
+	case "houses":
+		var house model.CreateHouseRequest
+		if err := json.NewDecoder(request.Body).Decode(&house); err != nil {
+			return http.StatusBadRequest, err.Error()
+		}
+		dto, err := s.newHouse(house)
+		if err != nil {
+			return http.StatusBadRequest, err.Error()
+		}
+		s.houses = append(s.houses, dto)
+		return http.StatusCreated, dto
+	case "houses/batch":
+		var batch model.CreateHouseBatchRequest
+		if err := json.NewDecoder(request.Body).Decode(&batch); err != nil {
+			return http.StatusBadRequest, err.Error()
+		}
+		return createBatch(s, batch.Houses, s.newHouse, func(dto model.HouseDto) { s.houses = append(s.houses, dto) })
+	case "providers/batch":
+		var batch model.CreateProviderBatchRequest
+		if err := json.NewDecoder(request.Body).Decode(&batch); err != nil {
+			return http.StatusBadRequest, err.Error()
+		}
+		return createBatch(s, batch.Providers, s.newProvider, func(dto model.ProviderDto) { s.providers = append(s.providers, dto) })
+	case "incomes/batch":
+		var batch model.CreateIncomeBatchRequest
+		if err := json.NewDecoder(request.Body).Decode(&batch); err != nil {
+			return http.StatusBadRequest, err.Error()
+		}
+		return createBatch(s, batch.Incomes, s.newIncome, func(dto model.IncomeDto) { s.incomes = append(s.incomes, dto) })
+	case "payments/batch":
+		var batch model.CreatePaymentBatchRequest
+		if err := json.NewDecoder(request.Body).Decode(&batch); err != nil {
+			return http.StatusBadRequest, err.Error()
+		}
+		return createBatch(s, batch.Payments, s.newPayment, func(dto model.PaymentDto) { s.payments = append(s.payments, dto) })
+	}
+
+	return http.StatusNotFound, "not found"
+}
+
+// createBatch creates all entities of the batch or none of them if a request is invalid.
+func createBatch[REQUEST any, DTO any](s *Server, requests []REQUEST, newDto func(REQUEST) (DTO, error), add func(DTO)) (int, any) {
+	if s.maxBatchSize != 0 && len(requests) > s.maxBatchSize {
+		return http.StatusRequestEntityTooLarge, fmt.Sprintf("batch of %d entities is larger than %d", len(requests), s.maxBatchSize)
+	}
+
+	dtos := make([]DTO, 0, len(requests))
+
+	for index, request := range requests {
+		dto, err := newDto(request)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Sprintf("entity %d: %s", index, err)
+		}
+		dtos = append(dtos, dto)
+	}
+
+	for _, dto := range dtos {
+		add(dto)
+	}
+
+	return http.StatusCreated, dtos
+}
+
+// truncate deletes the last entity of the batch response and removes it from the response.
+func (s *Server) truncate(request *http.Request, body any) any {
+	bodyBytes, _ := json.Marshal(body)
+
+	var entities []json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &entities); err != nil || len(entities) == 0 {
+		return body
+	}
+
+	var last struct {
+		Id uuid.UUID `json:"id"`
+	}
+	if err := json.Unmarshal(entities[len(entities)-1], &last); err != nil {
+		return body
+	}
+
+	entityType := strings.Split(strings.TrimPrefix(request.URL.Path, "/api/v1/"), "/")[0]
+	s.delete(entityType, last.Id.String())
+
+	return entities[:len(entities)-1]
+}
+
+func (s *Server) delete(entityType string, rawId string) (int, any) {
+	id, err := uuid.Parse(rawId)
+
+	if err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+
+	var deleted bool
+
+	switch entityType {
+	case "groups":
+		s.groups, deleted = remove(s.groups, func(group model.GroupDto) bool { return group.Id == id })
+	case "houses":
+		s.houses, deleted = remove(s.houses, func(house model.HouseDto) bool { return house.Id == id })
+	case "providers":
+		s.providers, deleted = remove(s.providers, func(provider model.ProviderDto) bool { return provider.Id == id })
+	case "incomes":
+		s.incomes, deleted = remove(s.incomes, func(income model.IncomeDto) bool { return income.Id == id })
+	case "payments":
+		s.payments, deleted = remove(s.payments, func(payment model.PaymentDto) bool { return payment.Id == id })
+	}
+
+	if !deleted {
+		return http.StatusNotFound, fmt.Sprintf("%s %s not found", entityType, id)
+	}
+
+	return http.StatusNoContent, nil
+}
+
+func (s *Server) newGroup(request model.CreateGroupRequest) (model.GroupDto, error) {
+	ownerId, err := s.user(request.OwnerId)
+
+	if err != nil {
+		return model.GroupDto{}, err
+	}
+
+	if request.Name == "" {
+		return model.GroupDto{}, fmt.Errorf("name is empty")
+	}
+
+	return model.GroupDto{Id: uuid.New(), Name: request.Name, OwnerId: ownerId}, nil
+}
+
+func (s *Server) newHouse(request model.CreateHouseRequest) (model.HouseDto, error) {
+	userId, err := s.user(request.UserId)
+
+	if err != nil {
+		return model.HouseDto{}, err
+	}
+
+	if request.Name == "" {
+		return model.HouseDto{}, fmt.Errorf("name is empty")
+	}
+
+	groups, err := s.groupsOf(request.GroupIds)
+
+	if err != nil {
+		return model.HouseDto{}, err
+	}
+
+	return model.HouseDto{
+		Id:          uuid.New(),
+		Name:        request.Name,
+		CountryCode: request.CountryCode,
+		City:        request.City,
+		StreetLine1: request.StreetLine1,
+		StreetLine2: request.StreetLine2,
+		UserId:      userId,
+		Groups:      groups,
+	}, nil
+}
+
+func (s *Server) newProvider(request model.CreateProviderRequest) (model.ProviderDto, error) {
+	userId, err := s.user(request.UserId)
+
+	if err != nil {
+		return model.ProviderDto{}, err
+	}
+
+	if request.Name == "" {
+		return model.ProviderDto{}, fmt.Errorf("name is empty")
+	}
+
+	return model.ProviderDto{
+		Id:          uuid.New(),
+		Name:        request.Name,
+		Description: request.Description,
+		Details:     request.Details,
+		UserId:      userId,
+	}, nil
+}
+
+func (s *Server) newIncome(request model.CreateIncomeRequest) (model.IncomeDto, error) {
+	date, err := time.Parse(time.RFC3339, request.Date)
+
+	if err != nil {
+		return model.IncomeDto{}, fmt.Errorf("invalid date %s", request.Date)
+	}
+
+	groups, err := s.groupsOfIds(request.GroupIds)
+
+	if err != nil {
+		return model.IncomeDto{}, err
+	}
+
+	income := model.IncomeDto{
+		Id:          uuid.New(),
+		Name:        request.Name,
+		Description: request.Description,
+		Date:        date,
+		Sum:         request.Sum,
+		Groups:      groups,
+	}
+
+	if request.HouseId != nil {
+		house, err := s.house(*request.HouseId)
+		if err != nil {
+			return model.IncomeDto{}, err
+		}
+		income.HouseId = house.Id
+	}
+
+	if request.HouseId == nil && len(groups) == 0 {
+		return model.IncomeDto{}, fmt.Errorf("income requires a house or groups")
+	}
+
+	return income, nil
+}
+
+func (s *Server) newPayment(request model.CreatePaymentRequest) (model.PaymentDto, error) {
+	date, err := time.Parse(time.RFC3339, request.Date)
+
+	if err != nil {
+		return model.PaymentDto{}, fmt.Errorf("invalid date %s", request.Date)
+	}
+
+	userId, err := s.user(request.UserId)
+
+	if err != nil {
+		return model.PaymentDto{}, err
+	}
+
+	groups, err := s.groupsOfIds(request.GroupIds)
+
+	if err != nil {
+		return model.PaymentDto{}, err
+	}
+
+	payment := model.PaymentDto{
+		Id:          uuid.New(),
+		Name:        request.Name,
+		Description: request.Description,
+		Groups:      groups,
+		UserId:      userId,
+		Date:        date,
+		Sum:         request.Sum,
+	}
+
+	if request.HouseId != nil {
+		house, err := s.house(*request.HouseId)
+		if err != nil {
+			return model.PaymentDto{}, err
+		}
+		payment.HouseId = house.Id
+	}
+
+	if request.HouseId == nil && len(groups) == 0 {
+		return model.PaymentDto{}, fmt.Errorf("payment requires a house or groups")
+	}
+
+	if request.ProviderId != nil {
+		provider, err := s.provider(*request.ProviderId)
+		if err != nil {
+			return model.PaymentDto{}, err
+		}
+		payment.ProviderId = provider.Id
+	}
+
+	return payment, nil
+}
+
+func (s *Server) user(rawId string) (uuid.UUID, error) {
+	id, err := uuid.Parse(rawId)
+
+	if err != nil || !s.users[id] {
+		return uuid.Nil, fmt.Errorf("user %s not found", rawId)
+	}
+
+	return id, nil
+}
+
+func (s *Server) house(rawId string) (model.HouseDto, error) {
+	id, err := uuid.Parse(rawId)
+
+	if err == nil {
+		for _, house := range s.houses {
+			if house.Id == id {
+				return house, nil
+			}
+		}
+	}
+
+	return model.HouseDto{}, fmt.Errorf("house %s not found", rawId)
+}
+
+func (s *Server) provider(rawId string) (model.ProviderDto, error) {
+	id, err := uuid.Parse(rawId)
+
+	if err == nil {
+		for _, provider := range s.providers {
+			if provider.Id == id {
+				return provider, nil
+			}
+		}
+	}
+
+	return model.ProviderDto{}, fmt.Errorf("provider %s not found", rawId)
+}
+
+// groupsOfIds returns the groups of the ids of an income or a payment.
+func (s *Server) groupsOfIds(rawIds []string) ([]model.GroupDto, error) {
+	var ids []uuid.UUID
+
+	for _, rawId := range rawIds {
+		id, err := uuid.Parse(rawId)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group id %s", rawId)
+		}
+		ids = append(ids, id)
+	}
+
+	return s.groupsOf(ids)
+}
+
+func (s *Server) groupsOf(ids []uuid.UUID) ([]model.GroupDto, error) {
+	var groups []model.GroupDto
+
+	for _, id := range ids {
+		index := -1
+
+		for i, group := range s.groups {
+			if group.Id == id {
+				index = i
+			}
+		}
+
+		if index == -1 {
+			return nil, fmt.Errorf("group %s not found", id)
+		}
+
+		groups = append(groups, s.groups[index])
+	}
+
+	return groups, nil
+}
+
+func hasGroup(groups []model.GroupDto, id uuid.UUID) bool {
+	for _, group := range groups {
+		if group.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func filter[T any](items []T, keep func(T) bool) []T {
+	filtered := []T{}
+
+	for _, item := range items {
+		if keep(item) {
+			filtered = append(filtered, item)
+		}
+	}
+
+	return filtered
+}
+
+func remove[T any](items []T, matches func(T) bool) ([]T, bool) {
+	for index, item := range items {
+		if matches(item) {
+			return append(items[:index], items[index+1:]...), true
+		}
+	}
+	return items, false
+}
@@ -0,0 +1,296 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	Created      = "created"
+	Deleted      = "deleted"
+	DeleteFailed = "delete-failed"
+)
+
+type Entry struct {
+	Time   time.Time       `json:"time"`
+	Type   string          `json:"type"`
+	Action string          `json:"action"`
+	Id     uuid.UUID       `json:"id"`
+	Key    string          `json:"key,omitempty"`
+	Line   int             `json:"line,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// Journal is an append only file of created and deleted entities. Every entry is synced to the disk before
+// the write returns, so the file can be used to rollback a migration that was interrupted.
+type Journal struct {
+	path      string
+	file      *os.File
+	mutex     sync.Mutex
+	restored  map[string]map[string]Entry
+	unmatched map[string][]Entry
+}
+
+func Open(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open journal %s", path)
+	}
+
+	if err := terminateLastEntry(path, file); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Journal{
+		path: path,
+		file: file,
+	}, nil
+}
+
+// Resume opens the journal of an interrupted migration. The entities created by the interrupted migration
+// and not deleted since then can be restored with Restore, the entities without a source key with Unmatched.
+func Resume(path string) (*Journal, error) {
+	entries, err := Read(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	journal, err := Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	journal.restored = make(map[string]map[string]Entry)
+	journal.unmatched = make(map[string][]Entry)
+
+	for _, entry := range Pending(entries) {
+		if entry.Key == "" {
+			journal.unmatched[entry.Type] = append(journal.unmatched[entry.Type], entry)
+			continue
+		}
+
+		if _, ok := journal.restored[entry.Type]; !ok {
+			journal.restored[entry.Type] = make(map[string]Entry)
+		}
+		journal.restored[entry.Type][entry.Key] = entry
+	}
+
+	return journal, nil
+}
+
+// Restore returns the entity created by the resumed migration for the source key.
+func Restore[T any](j *Journal, entityType string, key string) (t T, ok bool, err error) {
+	if j == nil {
+		return t, false, nil
+	}
+
+	entry, ok := j.restored[entityType][key]
+	if !ok {
+		return t, false, nil
+	}
+
+	if err := json.Unmarshal(entry.Data, &t); err != nil {
+		return t, false, errors.Wrapf(err, "failed to restore %s with id %s", entityType, entry.Id)
+	}
+
+	return t, true, nil
+}
+
+// Unmatched returns the entities created by the resumed migration that could not be matched with a source row.
+func Unmatched[T any](j *Journal, entityType string) ([]T, error) {
+	if j == nil {
+		return nil, nil
+	}
+
+	var unmatched []T
+
+	for _, entry := range j.unmatched[entityType] {
+		var t T
+
+		if err := json.Unmarshal(entry.Data, &t); err != nil {
+			return nil, errors.Wrapf(err, "failed to restore %s with id %s", entityType, entry.Id)
+		}
+
+		unmatched = append(unmatched, t)
+	}
+
+	return unmatched, nil
+}
+
+func (j *Journal) Path() string {
+	if j == nil {
+		return ""
+	}
+	return j.path
+}
+
+func (j *Journal) Created(entityType string, id uuid.UUID, key string, line int, data any) error {
+	if j == nil {
+		return nil
+	}
+
+	dataBytes, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	return j.write(Entry{
+		Type:   entityType,
+		Action: Created,
+		Id:     id,
+		Key:    key,
+		Line:   line,
+		Data:   dataBytes,
+	})
+}
+
+func (j *Journal) Deleted(entityType string, id uuid.UUID, deleteErr error) error {
+	if j == nil {
+		return nil
+	}
+
+	entry := Entry{
+		Type:   entityType,
+		Action: Deleted,
+		Id:     id,
+	}
+
+	if deleteErr != nil {
+		entry.Action = DeleteFailed
+		entry.Error = deleteErr.Error()
+	}
+
+	return j.write(entry)
+}
+
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.file.Close()
+}
+
+func (j *Journal) write(entry Entry) error {
+	entry.Time = time.Now().UTC()
+
+	entryBytes, err := json.Marshal(entry)
+
+	if err != nil {
+		return err
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if _, err := j.file.Write(append(entryBytes, '\n')); err != nil {
+		return errors.Wrapf(err, "failed to write journal %s", j.path)
+	}
+
+	return j.file.Sync()
+}
+
+// terminateLastEntry starts a new line if the journal ends with an incomplete entry, otherwise the next
+// entry would be appended to the broken one.
+func terminateLastEntry(path string, file *os.File) error {
+	info, err := file.Stat()
+
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+
+	reader, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer reader.Close()
+
+	lastByte := make([]byte, 1)
+
+	if _, err := reader.ReadAt(lastByte, info.Size()-1); err != nil {
+		return err
+	}
+
+	if lastByte[0] != '\n' {
+		_, err = file.Write([]byte{'\n'})
+	}
+
+	return err
+}
+
+func Read(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open journal %s", path)
+	}
+
+	defer file.Close()
+
+	var entries []Entry
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var corruptedErr error
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		if corruptedErr != nil {
+			return nil, corruptedErr
+		}
+
+		var entry Entry
+
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			corruptedErr = errors.Wrapf(err, "journal %s is corrupted at the line %d", path, lineNumber)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if corruptedErr != nil {
+		// only the last entry is broken, the process was stopped while the entry was written
+		log.Warn().Err(corruptedErr).Msg("Ignoring incomplete last journal entry")
+	}
+
+	return entries, scanner.Err()
+}
+
+// Pending returns the created entries that were not deleted yet, in the order of creation.
+func Pending(entries []Entry) []Entry {
+	deleted := make(map[uuid.UUID]bool)
+
+	for _, entry := range entries {
+		if entry.Action == Deleted {
+			deleted[entry.Id] = true
+		}
+	}
+
+	var pending []Entry
+
+	for _, entry := range entries {
+		if entry.Action == Created && !deleted[entry.Id] {
+			pending = append(pending, entry)
+		}
+	}
+
+	return pending
+}
@@ -0,0 +1,56 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/migrator"
+	"github.com/VlasovArtem/hob-migration/src/parser"
+)
+
+var (
+	ErrHobUnavailable = errors.New("hob api is not available")
+	ErrUserNotFound   = errors.New("user not found")
+	// ErrInterrupted is wrapped by the MigrationError of a migration cancelled by the context
+	ErrInterrupted = errors.New("migration interrupted")
+)
+
+// ConfigError is returned for invalid options or an invalid migrator file, before any request to HOB.
+type ConfigError struct {
+	Err error
+}
+
+func (c *ConfigError) Error() string {
+	return fmt.Sprintf("invalid configuration: %s", c.Err)
+}
+
+func (c *ConfigError) Unwrap() error {
+	return c.Err
+}
+
+// ValidationError is returned if the files have invalid rows, no data is sent to HOB.
+type ValidationError struct {
+	Errors []*parser.RowError
+}
+
+func (v *ValidationError) Error() string {
+	return fmt.Sprintf("%d errors found in the migration files, no data was sent to HOB", len(v.Errors))
+}
+
+// MigrationError is returned if the migration of a file failed. The entities created before the error were
+// rolled back with the result of the rollback, the rollback is nil if the entities of an interrupted or a
+// failed migration were kept.
+type MigrationError struct {
+	Err      error
+	Rollback *migrator.RollbackResult
+}
+
+func (m *MigrationError) Error() string {
+	if m.Rollback == nil {
+		return fmt.Sprintf("%s, the created entities were kept", m.Err)
+	}
+	return fmt.Sprintf("%s, rollback deleted %d entities and failed to delete %d entities", m.Err, m.Rollback.Deleted, m.Rollback.Failed)
+}
+
+func (m *MigrationError) Unwrap() error {
+	return m.Err
+}
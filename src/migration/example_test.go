@@ -0,0 +1,51 @@
+package migration_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/config"
+	"github.com/VlasovArtem/hob-migration/src/hobfake"
+	"github.com/VlasovArtem/hob-migration/src/migration"
+	"github.com/VlasovArtem/hob-migration/src/migrator"
+	"github.com/google/uuid"
+	"os"
+)
+
+func ExampleRun() {
+	server := hobfake.NewServer()
+	defer server.Close()
+
+	userId := uuid.New()
+	server.AddUser(userId)
+
+	journalDir, err := os.MkdirTemp("", "journal")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(journalDir)
+
+	cmdConfig := config.Default()
+	cmdConfig.HobURL = server.URL
+	cmdConfig.UserId = userId.String()
+	cmdConfig.JournalDir = journalDir
+
+	result, err := migration.Run(context.Background(), migration.Options{
+		Config: cmdConfig,
+		Files: map[string]migrator.EntityRequest{
+			migrator.GroupsType:    {Path: "../../example/groups.csv"},
+			migrator.HousesType:    {Path: "../../example/houses.csv"},
+			migrator.ProvidersType: {Path: "../../example/providers.csv"},
+			migrator.IncomesType:   {Path: "../../example/incomes.csv"},
+			migrator.PaymentsType:  {Path: "../../example/payments.csv"},
+		},
+	})
+
+	if err != nil {
+		fmt.Println("migration failed:", err)
+		return
+	}
+
+	fmt.Printf("%d groups, %d houses, %d providers, %d incomes, %d payments\n",
+		len(result.Groups), len(result.Houses), len(result.Providers), len(result.Incomes), len(result.Payments))
+	// Output: 2 groups, 3 houses, 2 providers, 4 incomes, 4 payments
+}
@@ -0,0 +1,358 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/client"
+	"github.com/VlasovArtem/hob-migration/src/config"
+	"github.com/VlasovArtem/hob-migration/src/journal"
+	"github.com/VlasovArtem/hob-migration/src/migrator"
+	"github.com/VlasovArtem/hob-migration/src/model"
+	"github.com/VlasovArtem/hob-migration/src/parser"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"io"
+)
+
+// Options of the migration.
+type Options struct {
+	// Config is the configuration of the migration, the same as the flags of the command line. It should start from
+	// config.Default(), the zero timeouts, delays, batch size and concurrency are replaced with the defaults
+	Config config.CMDConfig
+	// Files maps the entity types to the files of the migration, read from Config.MigratorFilePath if not set
+	Files map[string]migrator.EntityRequest
+	// ValidationOutput receives the table of the invalid rows, the table is not printed if it is not set
+	ValidationOutput io.Writer
+}
+
+// Result of the migration.
+type Result struct {
+	RunId       string
+	DryRun      bool
+	JournalPath string
+	// Groups, Houses and Providers are mapped by the names and the House Identifiers of the files
+	Groups    map[string]model.GroupDto
+	Houses    map[string]model.HouseDto
+	Providers map[string]model.ProviderDto
+	Incomes   []model.IncomeDto
+	Payments  []model.PaymentDto
+	// ValidationErrors are the invalid rows of the files
+	ValidationErrors []*parser.RowError
+	// Rollback is the result of the rollback of a failed migration
+	Rollback *migrator.RollbackResult
+	Report   *migrator.Report
+}
+
+// Run validates all files and migrates them to HOB. If the migration of a file fails or the context is cancelled, the
+// entities created before are rolled back and a MigrationError with the result of the rollback is returned. The
+// entities of a cancelled migration are kept with Config.KeepOnInterrupt, the entities of a failed migration are kept
+// with Config.KeepOnFailure or when the migration is resumed, so it can be resumed again.
+func Run(ctx context.Context, options Options) (Result, error) {
+	cmdConfig := options.Config.WithDefaults()
+
+	if cmdConfig.RunId == "" {
+		cmdConfig.RunId = cmdConfig.Resume
+	}
+	if cmdConfig.RunId == "" {
+		cmdConfig.RunId = config.NewRunId()
+	}
+
+	result := Result{RunId: cmdConfig.RunId, DryRun: cmdConfig.DryRun}
+
+	if cmdConfig.DryRun && cmdConfig.Resume != "" {
+		return result, &ConfigError{Err: errors.New("resume is not supported in the dry run mode")}
+	}
+
+	if cmdConfig.ReportMarkdown != "" && cmdConfig.ReportPath == "" {
+		return result, &ConfigError{Err: errors.New("markdown report requires the report path")}
+	}
+
+	requestMigrator, err := readRequestMigrator(options)
+
+	if err != nil {
+		return result, &ConfigError{Err: err}
+	}
+
+	hobClient, err := client.NewHobClient(&cmdConfig)
+
+	if err != nil {
+		return result, &ConfigError{Err: err}
+	}
+
+	if err := verifyUser(ctx, hobClient, cmdConfig.UserId); err != nil {
+		return result, err
+	}
+
+	if requestMigrator.References, err = migrator.LoadReferences(ctx, hobClient, cmdConfig.UserId); err != nil {
+		return result, err
+	}
+
+	if cmdConfig.ReportPath != "" {
+		result.Report = migrator.NewReport(cmdConfig.ReportPath, cmdConfig.ReportMarkdown, cmdConfig.RunId, cmdConfig.DryRun)
+	}
+
+	if err := validateFiles(ctx, requestMigrator, cmdConfig, hobClient, options.ValidationOutput, &result); err != nil {
+		return result, err
+	}
+
+	if cmdConfig.DryRun {
+		writeReport(result.Report, migrator.ReportCompleted)
+		log.Info().Msg("Completed hob-migration dry run, no data was sent to HOB")
+		return result, nil
+	}
+
+	migrationJournal, err := openJournal(&cmdConfig)
+
+	if err != nil {
+		return result, err
+	}
+
+	defer migrationJournal.Close()
+
+	result.JournalPath = migrationJournal.Path()
+	requestMigrator.Report = result.Report
+
+	rollbackOperations, err := migrateFiles(ctx, requestMigrator, &cmdConfig, hobClient, migrationJournal, &result)
+
+	if err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("%w: %v", ErrInterrupted, err)
+
+			if cmdConfig.KeepOnInterrupt {
+				log.Warn().Msgf("Migration interrupted, the created entities were kept. Continue with --resume %s or delete them with the rollback of the journal %s", cmdConfig.RunId, migrationJournal.Path())
+
+				writeReport(result.Report, migrator.ReportInterrupted)
+
+				return result, &MigrationError{Err: err}
+			}
+
+			log.Warn().Msg("Migration interrupted, rolling back the created entities")
+		} else if cmdConfig.KeepOnFailure || cmdConfig.Resume != "" {
+			log.Warn().Msgf("Migration failed, the created entities were kept. Continue with --resume %s or delete them with the rollback of the journal %s", cmdConfig.RunId, migrationJournal.Path())
+
+			writeReport(result.Report, migrator.ReportFailed)
+
+			return result, &MigrationError{Err: err}
+		}
+
+		// the rollback is not cancelled with the migration, otherwise the created entities would be left in HOB
+		rollbackResult := migrator.Rollback(context.Background(), rollbackOperations)
+		result.Rollback = &rollbackResult
+
+		writeReport(result.Report, migrator.ReportRolledBack)
+
+		return result, &MigrationError{Err: err, Rollback: &rollbackResult}
+	}
+
+	writeReport(result.Report, migrator.ReportCompleted)
+
+	log.Info().Msgf("Completed hob-migration, journal %s", migrationJournal.Path())
+
+	return result, nil
+}
+
+// Export writes the data of the user from HOB to the Config.OutputDir in the layout of the migration files.
+func Export(ctx context.Context, options Options) error {
+	cmdConfig := options.Config.WithDefaults()
+
+	if cmdConfig.OutputDir == "" {
+		return &ConfigError{Err: errors.New("output directory is empty")}
+	}
+
+	hobClient, err := client.NewHobClient(&cmdConfig)
+
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+
+	if err := verifyUser(ctx, hobClient, cmdConfig.UserId); err != nil {
+		return err
+	}
+
+	return migrator.Export(ctx, hobClient, cmdConfig.UserId, cmdConfig.OutputDir)
+}
+
+// Rollback deletes the entities of the journal Config.JournalPath that were not deleted yet.
+func Rollback(ctx context.Context, options Options) error {
+	cmdConfig := options.Config.WithDefaults()
+
+	if cmdConfig.JournalPath == "" {
+		return &ConfigError{Err: errors.New("journal path is empty")}
+	}
+
+	hobClient, err := client.NewHobClient(&cmdConfig)
+
+	if err != nil {
+		return &ConfigError{Err: err}
+	}
+
+	if err := hobClient.HealthCheck(ctx); err != nil {
+		return fmt.Errorf("%w: %v", ErrHobUnavailable, err)
+	}
+
+	return migrator.RollbackJournal(ctx, cmdConfig.JournalPath, hobClient)
+}
+
+func migrateFiles(
+	ctx context.Context,
+	requestMigrator migrator.RequestMigrator,
+	cmdConfig *config.CMDConfig,
+	hobClient *client.HobClient,
+	migrationJournal *journal.Journal,
+	result *Result,
+) (rollbackOperations []migrator.RollbackOperation, err error) {
+	result.Groups, rollbackOperations, err = migrator.
+		NewGroupMigrator(requestMigrator, cmdConfig, hobClient, migrationJournal).
+		Migrate(ctx, rollbackOperations)
+
+	if err != nil {
+		return rollbackOperations, err
+	}
+
+	result.Houses, rollbackOperations, err = migrator.
+		NewHouseMigrator(requestMigrator, cmdConfig, hobClient, migrationJournal, result.Groups).
+		Migrate(ctx, rollbackOperations)
+
+	if err != nil {
+		return rollbackOperations, err
+	}
+
+	result.Providers, rollbackOperations, err = migrator.
+		NewProviderMigrator(requestMigrator, cmdConfig, hobClient, migrationJournal).
+		Migrate(ctx, rollbackOperations)
+
+	if err != nil {
+		return rollbackOperations, err
+	}
+
+	result.Incomes, rollbackOperations, err = migrator.
+		NewIncomeMigrator(requestMigrator, cmdConfig, hobClient, migrationJournal, result.Houses, result.Groups).
+		Migrate(ctx, rollbackOperations)
+
+	if err != nil {
+		return rollbackOperations, err
+	}
+
+	result.Payments, rollbackOperations, err = migrator.
+		NewPaymentMigrator(requestMigrator, cmdConfig, hobClient, migrationJournal, result.Houses, result.Groups, result.Providers).
+		Migrate(ctx, rollbackOperations)
+
+	return rollbackOperations, err
+}
+
+// validateFiles runs a dry run of all files and returns the errors of all files before any data is sent to HOB.
+func validateFiles(
+	ctx context.Context,
+	requestMigrator migrator.RequestMigrator,
+	cmdConfig config.CMDConfig,
+	hobClient *client.HobClient,
+	output io.Writer,
+	result *Result,
+) error {
+	log.Info().Msg("Validating migration files")
+
+	dryRun := cmdConfig.DryRun
+	cmdConfig.DryRun = true
+
+	validation := &migrator.Validation{Quiet: !dryRun}
+	requestMigrator.Validation = validation
+
+	// the report of a migration is filled by the migration, the validation reports only the dry run
+	if dryRun {
+		requestMigrator.Report = result.Report
+	}
+
+	validationResult := &Result{}
+	if dryRun {
+		validationResult = result
+	}
+
+	// the errors are collected by the validation
+	migrateFiles(ctx, requestMigrator, &cmdConfig, hobClient, nil, validationResult)
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInterrupted, err)
+	}
+
+	if len(validation.Errors) == 0 {
+		log.Info().Msg("Migration files are valid")
+		return nil
+	}
+
+	result.ValidationErrors = validation.Errors
+
+	if output != nil {
+		if err := validation.Print(output); err != nil {
+			log.Error().Err(err).Msg("Failed to print validation errors")
+		}
+	}
+
+	if cmdConfig.ErrorsFilePath != "" {
+		if err := validation.WriteFile(cmdConfig.ErrorsFilePath); err != nil {
+			log.Error().Err(err).Msgf("Failed to write validation errors to %s", cmdConfig.ErrorsFilePath)
+		} else {
+			log.Info().Msgf("Validation errors written to %s", cmdConfig.ErrorsFilePath)
+		}
+	}
+
+	writeReport(result.Report, migrator.ReportInvalid)
+
+	return &ValidationError{Errors: validation.Errors}
+}
+
+func writeReport(report *migrator.Report, status string) {
+	if report == nil {
+		return
+	}
+
+	if err := report.Write(status); err != nil {
+		log.Error().Err(err).Msg("Failed to write report")
+		return
+	}
+
+	log.Info().Msgf("Report written to %s", report.Path())
+}
+
+func openJournal(cmdConfig *config.CMDConfig) (*journal.Journal, error) {
+	if cmdConfig.Resume != "" {
+		migrationJournal, err := journal.Resume(cmdConfig.MigrationJournalPath())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resume run %s", cmdConfig.Resume)
+		}
+
+		log.Info().Msgf("Resuming run %s from journal %s", cmdConfig.RunId, migrationJournal.Path())
+
+		return migrationJournal, nil
+	}
+
+	migrationJournal, err := journal.Open(cmdConfig.MigrationJournalPath())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open migration journal")
+	}
+
+	log.Info().Msgf("Run %s journal %s", cmdConfig.RunId, migrationJournal.Path())
+
+	return migrationJournal, nil
+}
+
+func verifyUser(ctx context.Context, hobClient *client.HobClient, userId string) error {
+	if err := hobClient.HealthCheck(ctx); err != nil {
+		return fmt.Errorf("%w: %v", ErrHobUnavailable, err)
+	}
+
+	if !hobClient.UserExists(ctx, userId) {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, userId)
+	}
+
+	return nil
+}
+
+func readRequestMigrator(options Options) (requestMigrator migrator.RequestMigrator, err error) {
+	if options.Files != nil {
+		requestMigrator.TypeToRequestMap = options.Files
+		return requestMigrator, migrator.VerifyManifest(options.Files)
+	}
+
+	requestMigrator.TypeToRequestMap, err = migrator.ReadManifest(options.Config.MigratorFilePath)
+	return requestMigrator, err
+}
@@ -1,16 +1,141 @@
 package migrator
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/config"
+	"github.com/VlasovArtem/hob-migration/src/journal"
 	"github.com/VlasovArtem/hob-migration/src/parser"
 	"github.com/VlasovArtem/hob-migration/src/validator"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
-	"os"
 	"path/filepath"
 	"strings"
+	"time"
+)
+
+const (
+	GroupsType    = "groups"
+	HousesType    = "houses"
+	ProvidersType = "providers"
+	IncomesType   = "incomes"
+	PaymentsType  = "payments"
 )
 
 type RequestMigrator struct {
-	TypeToPathMap map[string]string
+	TypeToRequestMap map[string]EntityRequest
+	// Validation collects the errors of the files instead of the rollback of the migration
+	Validation *Validation
+	// Report collects the entities created from the rows of the files
+	Report *Report
+	// References are the existing houses and groups the rows can reference by id or name
+	References *References
+}
+
+// EntityRequest is an entry of the migrator file, either the path to the file or an object with the path and
+// the options of the file.
+type EntityRequest struct {
+	Path string `json:"path,omitempty"`
+	// Format is the format of the file, the extension of the path if not set
+	Format string `json:"format,omitempty"`
+	// Delimiter and Encoding of a CSV file, a comma and UTF-8 if not set
+	Delimiter string `json:"delimiter,omitempty"`
+	Encoding  string `json:"encoding,omitempty"`
+	// DateLayout and DateLayouts are the Go layouts of the dates, e.g. 02.01.2006, or excel for the Excel serial
+	// dates, parser.DefaultDateLayouts if not set
+	DateLayout  string   `json:"dateLayout,omitempty"`
+	DateLayouts []string `json:"dateLayouts,omitempty"`
+	// TimeZone is the IANA time zone of the dates without an offset, e.g. Europe/Kyiv, UTC if not set
+	TimeZone string `json:"timeZone,omitempty"`
+	// House is the House Identifier the transactions of an OFX file belong to
+	House string `json:"house,omitempty"`
+	// Columns maps the columns of a CSV or XLSX file to the columns of the migrator
+	Columns map[string]parser.ColumnMapping `json:"columns,omitempty"`
+	// DecimalSeparator and ThousandsSeparator of the amounts, detected for every value if not set
+	DecimalSeparator   string `json:"decimalSeparator,omitempty"`
+	ThousandsSeparator string `json:"thousandsSeparator,omitempty"`
+	// BatchSize is the max number of entities of a batch request, the --batch-size if not set
+	BatchSize int `json:"batchSize,omitempty"`
+	// Currency is the currency the amounts of the incomes or payments in other currencies are converted to with the
+	// rates of the ExchangeRates CSV file
+	Currency      string `json:"currency,omitempty"`
+	ExchangeRates string `json:"exchangeRates,omitempty"`
+}
+
+func (e EntityRequest) batchSize(config *config.CMDConfig) int {
+	if e.BatchSize > 0 {
+		return e.BatchSize
+	}
+	return config.BatchSize
+}
+
+func (e EntityRequest) format() string {
+	if e.Format != "" {
+		return strings.ToLower(e.Format)
+	}
+	return fileType(e.Path)
+}
+
+func (e EntityRequest) csvFormat() parser.CSVFormat {
+	format := parser.CSVFormat{Encoding: e.Encoding}
+	if e.Delimiter != "" {
+		format.Delimiter = []rune(e.Delimiter)[0]
+	}
+	return format
+}
+
+func (e EntityRequest) dateFormat() parser.DateFormat {
+	format := parser.DateFormat{Layouts: e.dateLayouts()}
+
+	// the time zone is verified with the migrator file
+	if location, err := time.LoadLocation(e.TimeZone); err == nil {
+		format.Location = location
+	}
+
+	return format
+}
+
+func (e EntityRequest) dateLayouts() []string {
+	if e.DateLayout == "" {
+		return e.DateLayouts
+	}
+	return append([]string{e.DateLayout}, e.DateLayouts...)
+}
+
+func (e EntityRequest) amountFormat() parser.AmountFormat {
+	return parser.AmountFormat{
+		DecimalSeparator:   e.DecimalSeparator,
+		ThousandsSeparator: e.ThousandsSeparator,
+	}
+}
+
+// xlsxAmountFormat reads the raw values of numeric cells as is, text cells are parsed with the separators.
+func (e EntityRequest) xlsxAmountFormat() parser.AmountFormat {
+	format := e.amountFormat()
+	format.RawNumbers = true
+	return format
+}
+
+func (e *EntityRequest) UnmarshalJSON(data []byte) error {
+	var path string
+
+	if err := json.Unmarshal(data, &path); err == nil {
+		e.Path = path
+		return nil
+	}
+
+	type entityRequest EntityRequest
+
+	// a misspelled option is an error instead of being ignored
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode((*entityRequest)(e))
 }
 
 type Migrator[RESPONSE any] interface {
@@ -18,33 +143,107 @@ type Migrator[RESPONSE any] interface {
 }
 
 type Mapper[RESPONSE any] interface {
-	Map() (RESPONSE, error)
+	Map(ctx context.Context) (RESPONSE, error)
 }
 
 type BaseMigrator[RESPONSE any] struct {
-	mappers  map[string]Mapper[RESPONSE]
-	filePath string
-	rollback func(RESPONSE)
+	mappers    map[string]Mapper[RESPONSE]
+	filePath   string
+	format     string
+	rollback   func(ctx context.Context, data RESPONSE) RollbackResult
+	dryRun     bool
+	validation *Validation
+	entityType string
+	report     *Report
+	// prepare reads the files the rows depend on before the file is read, optional
+	prepare func() error
+}
+
+// RollbackOperation deletes the entities created by the migration of a file.
+type RollbackOperation func(ctx context.Context) RollbackResult
+
+// RollbackResult is the number of the entities deleted by a rollback and the number of the entities that failed to
+// be deleted.
+type RollbackResult struct {
+	Deleted int
+	Failed  int
 }
 
-func (b *BaseMigrator[RESPONSE]) Migrate(rollbackOnError []func()) (RESPONSE, []func()) {
+func (r *RollbackResult) add(err error) {
+	if err != nil {
+		r.Failed++
+	} else {
+		r.Deleted++
+	}
+}
+
+// StageError is the error of the migration of the file of an entity type.
+type StageError struct {
+	EntityType string
+	FilePath   string
+	Err        error
+}
+
+func (s *StageError) Error() string {
+	return fmt.Sprintf("failed to migrate %s from %s: %s", s.EntityType, s.FilePath, s.Err)
+}
+
+func (s *StageError) Unwrap() error {
+	return s.Err
+}
+
+// Migrate migrates the file and appends the rollback of the created entities to the rollback operations. The
+// entities created before an error are rolled back as well, so the operations are returned with the error.
+func (b *BaseMigrator[RESPONSE]) Migrate(ctx context.Context, rollbackOperations []RollbackOperation) (RESPONSE, []RollbackOperation, error) {
 	if b == nil {
-		return *new(RESPONSE), rollbackOnError
+		return *new(RESPONSE), rollbackOperations, nil
+	}
+
+	err := b.Verify()
+
+	if err == nil && b.prepare != nil {
+		err = b.prepare()
 	}
 
-	if err := b.Verify(); err != nil {
+	if err != nil {
 		log.Err(err).Msg("Verify error")
-		rollback(rollbackOnError)
+
+		b.report.finish(b.entityType, b.filePath, 0, err)
+
+		if b.validation != nil {
+			b.validation.add(b.filePath, err)
+			return *new(RESPONSE), rollbackOperations, nil
+		}
+
+		return *new(RESPONSE), rollbackOperations, &StageError{EntityType: b.entityType, FilePath: b.filePath, Err: err}
 	}
 
-	t, err := b.mappers[strings.Replace(filepath.Ext(b.filePath), ".", "", 1)].Map()
+	start := time.Now()
+
+	t, err := b.mappers[b.format].Map(ctx)
+
+	b.report.finish(b.entityType, b.filePath, time.Since(start), err)
+
+	if !b.dryRun {
+		rollbackOperations = append(rollbackOperations, func(ctx context.Context) RollbackResult { return b.rollback(ctx, t) })
+	}
 
 	if err != nil {
 		log.Error().Err(err).Msg("Error while migrating")
-		rollback(rollbackOnError)
+
+		if b.validation != nil {
+			b.validation.add(b.filePath, err)
+			return t, rollbackOperations, nil
+		}
+
+		return t, rollbackOperations, &StageError{EntityType: b.entityType, FilePath: b.filePath, Err: err}
 	}
 
-	return t, append(rollbackOnError, func() { b.rollback(t) })
+	return t, rollbackOperations, nil
+}
+
+func (b *BaseMigrator[T]) printRequests() bool {
+	return b.validation == nil || !b.validation.Quiet
 }
 
 func (b *BaseMigrator[T]) Verify() error {
@@ -53,7 +252,13 @@ func (b *BaseMigrator[T]) Verify() error {
 			return validator.VerifyFilePathIsEmpty(b.filePath, "apartments file path is empty")
 		},
 		func() error {
-			return validator.VerifyFilePathTypeIsValid(b.filePath)
+			return validator.VerifyFormatIsValid(b.format)
+		},
+		func() error {
+			if _, ok := b.mappers[b.format]; !ok {
+				return fmt.Errorf("format %s is not supported for the file %s", b.format, b.filePath)
+			}
+			return nil
 		},
 		func() error {
 			return validator.VerifyFilePathExists(b.filePath)
@@ -61,32 +266,299 @@ func (b *BaseMigrator[T]) Verify() error {
 	)
 }
 
-func rollback(rollbackOnError []func()) {
-	if len(rollbackOnError) != 0 {
-		for i := len(rollbackOnError) - 1; i >= 0; i-- {
-			rollbackOnError[i]()
+func fileType(path string) string {
+	return strings.Replace(filepath.Ext(path), ".", "", 1)
+}
+
+// Rollback runs the rollback operations in the reverse order of the migration.
+func Rollback(ctx context.Context, rollbackOperations []RollbackOperation) RollbackResult {
+	var result RollbackResult
+
+	for i := len(rollbackOperations) - 1; i >= 0; i-- {
+		operationResult := rollbackOperations[i](ctx)
+		result.Deleted += operationResult.Deleted
+		result.Failed += operationResult.Failed
+	}
+
+	return result
+}
+
+func logDryRun[REQUEST any](printRequests bool, entityType string, requests []REQUEST) {
+	if !printRequests {
+		return
+	}
+
+	log.Info().Msgf("[dry-run] %d %s would be created", len(requests), entityType)
+
+	for _, request := range requests {
+		log.Info().Interface("request", request).Msgf("[dry-run] %s request", entityType)
+	}
+}
+
+// Row is a parsed request together with the line of the source file it was read from.
+type Row[REQUEST any] struct {
+	Line    int
+	Request REQUEST
+}
+
+func requestsOf[REQUEST any](rows []Row[REQUEST]) []REQUEST {
+	requests := make([]REQUEST, 0, len(rows))
+
+	for _, row := range rows {
+		requests = append(requests, row.Request)
+	}
+
+	return requests
+}
+
+// contentKeys keys the rows by the hash of their requests, so the entities of a resumed migration are restored for
+// the same rows even if the lines of the file were changed. Identical requests are numbered in the order of the file.
+func contentKeys[REQUEST any](rows []Row[REQUEST]) (map[int]string, error) {
+	keys := make(map[int]string, len(rows))
+	occurrences := make(map[string]int)
+
+	for _, row := range rows {
+		data, err := json.Marshal(row.Request)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+
+		occurrences[hash]++
+		keys[row.Line] = fmt.Sprintf("%s#%d", hash, occurrences[hash])
+	}
+
+	return keys, nil
+}
+
+// resumeRows splits the rows into the entities restored from the journal of the resumed migration and the rows
+// that still have to be migrated. The entities of the resumed migration that could not be matched with a row are
+// passed to discard, their rows are migrated again.
+func resumeRows[REQUEST any, DTO any](
+	migrationJournal *journal.Journal,
+	entityType string,
+	rows []Row[REQUEST],
+	key func(row Row[REQUEST]) string,
+	onRestored func(row Row[REQUEST], dto DTO),
+	discard func(unmatched []DTO),
+) (restored map[string]DTO, pending []Row[REQUEST], err error) {
+	restored = make(map[string]DTO)
+
+	unmatched, err := journal.Unmatched[DTO](migrationJournal, entityType)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(unmatched) != 0 {
+		log.Warn().Msgf("%d %s of the resumed migration are not matched with the rows and are deleted", len(unmatched), entityType)
+		discard(unmatched)
+	}
+
+	for _, row := range rows {
+		rowKey := key(row)
+
+		if dto, ok, err := journal.Restore[DTO](migrationJournal, entityType, rowKey); err != nil {
+			return nil, nil, err
+		} else if ok {
+			restored[rowKey] = dto
+			onRestored(row, dto)
+		} else {
+			pending = append(pending, row)
 		}
 	}
 
-	os.Exit(1)
+	if len(restored) != 0 {
+		log.Info().Msgf("%d %s restored from the journal, %d %s left to migrate", len(restored), entityType, len(pending), entityType)
+	}
+
+	return restored, pending, nil
+}
+
+// byId keys the entities by id, e.g. to roll back the entities that are not matched with a row.
+func byId[DTO any](dtos []DTO, id func(dto DTO) uuid.UUID) map[string]DTO {
+	keyed := make(map[string]DTO, len(dtos))
+
+	for _, dto := range dtos {
+		keyed[id(dto).String()] = dto
+	}
+
+	return keyed
+}
+
+// mapRows maps the valid rows even if the parser found invalid ones, so the dry run reports the errors of the
+// files that depend on the mapped entities as well. The errors of the invalid rows are returned after the mapping.
+func mapRows[REQUEST any, RESPONSE any](
+	ctx context.Context,
+	rows []Row[REQUEST],
+	parseErr error,
+	mapper func(ctx context.Context, rows []Row[REQUEST]) (RESPONSE, error),
+) (response RESPONSE, err error) {
+	var rowErrors parser.RowErrors
+
+	if parseErr != nil && !errors.As(parseErr, &rowErrors) {
+		return response, parseErr
+	}
+
+	response, err = mapper(ctx, rows)
+
+	if parseErr != nil {
+		return response, parseErr
+	}
+
+	return response, err
 }
 
 type CSVMigrator[REQUEST any, RESPONSE any] struct {
 	filePath string
+	format   parser.CSVFormat
 	header   []string
+	columns  map[string]parser.ColumnMapping
 	parser   func(line []string, lineNumber int) (REQUEST, error)
-	mapper   func(requests []REQUEST) (RESPONSE, error)
+	mapper   func(ctx context.Context, rows []Row[REQUEST]) (RESPONSE, error)
 }
 
-func (c *CSVMigrator[REQUEST, RESPONSE]) Map() (response RESPONSE, err error) {
+func (c *CSVMigrator[REQUEST, RESPONSE]) Map(ctx context.Context) (response RESPONSE, err error) {
 	log.Info().Msgf("Start CSV Migration for file: %s", c.filePath)
 
-	requests, err := parser.Parse[REQUEST](c.filePath, c.header, c.parser)
+	rows, err := parser.Parse(c.filePath, c.format, c.header, c.columns, func(line []string, lineNumber int) (Row[REQUEST], error) {
+		request, err := c.parser(line, lineNumber)
+
+		return Row[REQUEST]{Line: lineNumber, Request: request}, err
+	})
 
 	if err != nil {
 		log.Error().Err(err).Msgf("Error while parsing CSV file")
+	}
+
+	return mapRows(ctx, rows, err, c.mapper)
+}
+
+type JSONMigrator[RECORD any, REQUEST any, RESPONSE any] struct {
+	filePath string
+	parser   func(record RECORD, lineNumber int) (REQUEST, error)
+	mapper   func(ctx context.Context, rows []Row[REQUEST]) (RESPONSE, error)
+}
+
+func (j *JSONMigrator[RECORD, REQUEST, RESPONSE]) Map(ctx context.Context) (response RESPONSE, err error) {
+	log.Info().Msgf("Start JSON Migration for file: %s", j.filePath)
+
+	rows, err := parser.ParseJSON(j.filePath, func(record RECORD, lineNumber int) (Row[REQUEST], error) {
+		request, err := j.parser(record, lineNumber)
+
+		return Row[REQUEST]{Line: lineNumber, Request: request}, err
+	})
+
+	if err != nil {
+		log.Error().Err(err).Msgf("Error while parsing JSON file")
+	}
+
+	return mapRows(ctx, rows, err, j.mapper)
+}
+
+// XLSXMigrator reads one sheet of a workbook with the same header and line parser as CSVMigrator.
+type XLSXMigrator[REQUEST any, RESPONSE any] struct {
+	filePath    string
+	sheet       string
+	header      []string
+	columns     map[string]parser.ColumnMapping
+	dateColumns []string
+	parser      func(line []string, lineNumber int) (REQUEST, error)
+	mapper      func(ctx context.Context, rows []Row[REQUEST]) (RESPONSE, error)
+}
+
+func (x *XLSXMigrator[REQUEST, RESPONSE]) Map(ctx context.Context) (response RESPONSE, err error) {
+	log.Info().Msgf("Start XLSX Migration for sheet %s of the file: %s", x.sheet, x.filePath)
+
+	rows, err := parser.ParseXLSX(x.filePath, x.sheet, x.header, x.columns, x.dateColumns, func(line []string, lineNumber int) (Row[REQUEST], error) {
+		request, err := x.parser(line, lineNumber)
+
+		return Row[REQUEST]{Line: lineNumber, Request: request}, err
+	})
+
+	if err != nil {
+		log.Error().Err(err).Msgf("Error while parsing XLSX file")
+	}
+
+	return mapRows(ctx, rows, err, x.mapper)
+}
+
+// OFXMigrator reads the transactions of an OFX bank statement. The transactions are numbered from 1 in the order of
+// the file, the filter selects the transactions of the migrator.
+type OFXMigrator[REQUEST any, RESPONSE any] struct {
+	filePath string
+	filter   func(transaction parser.OFXTransaction) bool
+	parser   func(transaction parser.OFXTransaction, lineNumber int) (REQUEST, error)
+	mapper   func(ctx context.Context, rows []Row[REQUEST]) (RESPONSE, error)
+}
+
+func (o *OFXMigrator[REQUEST, RESPONSE]) Map(ctx context.Context) (response RESPONSE, err error) {
+	log.Info().Msgf("Start OFX Migration for file: %s", o.filePath)
+
+	transactions, err := parser.ReadOFX(o.filePath)
+
+	var rowErrors parser.RowErrors
+
+	if err != nil && !errors.As(err, &rowErrors) {
+		log.Error().Err(err).Msgf("Error while parsing OFX file")
 		return response, err
 	}
 
-	return c.mapper(requests)
+	var rows []Row[REQUEST]
+
+	for _, transaction := range transactions {
+		if !o.filter(transaction) {
+			continue
+		}
+
+		if request, err := o.parser(transaction, transaction.Number); err != nil {
+			rowErrors = append(rowErrors, parser.ToRowError(err, o.filePath, transaction.Number))
+		} else {
+			rows = append(rows, Row[REQUEST]{Line: transaction.Number, Request: request})
+		}
+	}
+
+	if len(rowErrors) != 0 {
+		err = rowErrors
+		log.Error().Err(err).Msgf("Error while parsing OFX file")
+	}
+
+	return mapRows(ctx, rows, err, o.mapper)
+}
+
+// splitList splits a comma separated CSV value, like a list of group names.
+func splitList(value string) []string {
+	if len(value) == 0 {
+		return nil
+	}
+
+	var items []string
+
+	for _, item := range strings.Split(value, ",") {
+		items = append(items, strings.TrimSpace(item))
+	}
+
+	return items
+}
+
+// withOptionalColumns adds an empty default to the optional columns that are not mapped, so the files without these
+// columns are valid.
+func withOptionalColumns(columns map[string]parser.ColumnMapping, optional ...string) map[string]parser.ColumnMapping {
+	mapped := make(map[string]parser.ColumnMapping, len(columns)+len(optional))
+
+	for column, mapping := range columns {
+		mapped[column] = mapping
+	}
+
+	for _, column := range optional {
+		if _, ok := mapped[column]; !ok {
+			empty := ""
+			mapped[column] = parser.ColumnMapping{Default: &empty}
+		}
+	}
+
+	return mapped
 }
@@ -0,0 +1,163 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/client"
+	"github.com/VlasovArtem/hob-migration/src/config"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"sync"
+)
+
+// createInChunks creates the rows with batch requests of up to chunkSize rows. The chunk is halved and sent again
+// if HOB rejects it as too large. A chunk that timed out is not halved or sent again, because HOB could have created
+// it, and a smaller chunk would create its entities twice. The entities of the chunks created before an error are
+// returned with the error, so only they are rolled back. If HOB responds with a different number of entities than
+// it was sent, the entities cannot be matched with the rows and are passed to unmatched instead of created.
+func createInChunks[REQUEST any, DTO any](
+	ctx context.Context,
+	entityType string,
+	rows []Row[REQUEST],
+	chunkSize int,
+	create func(ctx context.Context, requests []REQUEST) ([]DTO, error),
+	created func(row Row[REQUEST], dto DTO) error,
+	unmatched func(dto DTO) error,
+) ([]DTO, error) {
+	if chunkSize <= 0 {
+		chunkSize = config.Default().BatchSize
+	}
+
+	var responses []DTO
+
+	for start := 0; start < len(rows); {
+		end := start + chunkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return responses, err
+		}
+
+		chunk := rows[start:end]
+
+		response, err := create(ctx, requestsOf(chunk))
+
+		if err != nil {
+			if client.IsStatus(err, http.StatusRequestEntityTooLarge) && chunkSize > 1 {
+				chunkSize = (len(chunk) + 1) / 2
+				log.Warn().Err(err).Msgf("Failed to create chunk of %d %s, retrying with chunks of %d", len(chunk), entityType, chunkSize)
+				continue
+			}
+
+			log.Error().Err(err).Msgf("Failed to create %s of the lines %d-%d", entityType, chunk[0].Line, chunk[len(chunk)-1].Line)
+			return responses, err
+		}
+
+		if len(response) != len(chunk) {
+			log.Error().Msgf("HOB created %d %s of the lines %d-%d, expected %d", len(response), entityType, chunk[0].Line, chunk[len(chunk)-1].Line, len(chunk))
+
+			for _, dto := range response {
+				responses = append(responses, dto)
+
+				if err := unmatched(dto); err != nil {
+					return responses, err
+				}
+			}
+
+			return responses, fmt.Errorf("hob created %d %s of a batch of %d", len(response), entityType, len(chunk))
+		}
+
+		// the batch response keeps the order of the requests
+		for index, dto := range response {
+			responses = append(responses, dto)
+
+			if err := created(chunk[index], dto); err != nil {
+				return responses, err
+			}
+		}
+
+		start = end
+
+		log.Info().Msgf("%d of %d %s created", start, len(rows), entityType)
+	}
+
+	return responses, nil
+}
+
+// createConcurrently creates the rows one by one with up to concurrency requests at a time. No new requests are
+// sent after an error, the first error is returned when the requests in flight are completed.
+func createConcurrently[REQUEST any, DTO any](
+	ctx context.Context,
+	entityType string,
+	rows []Row[REQUEST],
+	concurrency int,
+	create func(ctx context.Context, request REQUEST) (DTO, error),
+	created func(row Row[REQUEST], dto DTO) error,
+) error {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var (
+		waitGroup sync.WaitGroup
+		mutex     sync.Mutex
+		firstErr  error
+		count     int
+	)
+
+	semaphore := make(chan struct{}, concurrency)
+
+	failed := func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return firstErr != nil
+	}
+
+	for _, row := range rows {
+		semaphore <- struct{}{}
+
+		if failed() || ctx.Err() != nil {
+			<-semaphore
+			break
+		}
+
+		waitGroup.Add(1)
+
+		go func(row Row[REQUEST]) {
+			defer func() {
+				<-semaphore
+				waitGroup.Done()
+			}()
+
+			dto, err := create(ctx, row.Request)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err == nil {
+				err = created(row, dto)
+				count++
+			}
+
+			if err != nil {
+				log.Error().Err(err).Msgf("Failed to create %s of the line %d", entityType, row.Line)
+
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}(row)
+	}
+
+	waitGroup.Wait()
+
+	log.Info().Msgf("%d of %d %s created", count, len(rows), entityType)
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+
+	return firstErr
+}
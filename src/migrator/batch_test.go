@@ -0,0 +1,93 @@
+package migrator
+
+import (
+	"context"
+	"github.com/VlasovArtem/hob-migration/src/client"
+	"golang.org/x/exp/slices"
+	"net/http"
+	"testing"
+)
+
+func testRows(count int) []Row[int] {
+	rows := make([]Row[int], count)
+
+	for index := range rows {
+		rows[index] = Row[int]{Line: index + 2, Request: index}
+	}
+
+	return rows
+}
+
+func TestCreateInChunksShrinksTooLargeBatches(t *testing.T) {
+	var sizes []int
+
+	created, err := createInChunks(context.Background(), IncomesType, testRows(5), 4, func(ctx context.Context, requests []int) ([]int, error) {
+		sizes = append(sizes, len(requests))
+
+		if len(requests) > 2 {
+			return nil, &client.ResponseError{StatusCode: http.StatusRequestEntityTooLarge}
+		}
+		return requests, nil
+	}, func(row Row[int], dto int) error {
+		if row.Request != dto {
+			t.Errorf("expected the row %d created as %d, got %d", row.Line, row.Request, dto)
+		}
+		return nil
+	}, func(dto int) error {
+		t.Errorf("expected no unmatched entities, got %d", dto)
+		return nil
+	})
+
+	if err != nil || len(created) != 5 {
+		t.Fatalf("expected 5 created rows, got %v, %v", created, err)
+	}
+
+	if expected := []int{4, 2, 2, 1}; !slices.Equal(sizes, expected) {
+		t.Errorf("expected batches of %v, got %v", expected, sizes)
+	}
+}
+
+func TestCreateInChunksDoesNotResendFailedBatches(t *testing.T) {
+	for _, statusCode := range []int{http.StatusGatewayTimeout, http.StatusInternalServerError} {
+		requests := 0
+
+		_, err := createInChunks(context.Background(), IncomesType, testRows(4), 4, func(ctx context.Context, batch []int) ([]int, error) {
+			requests++
+			return nil, &client.ResponseError{StatusCode: statusCode}
+		}, func(row Row[int], dto int) error {
+			return nil
+		}, func(dto int) error {
+			return nil
+		})
+
+		if !client.IsStatus(err, statusCode) || requests != 1 {
+			t.Errorf("expected the batch of the status %d sent once, got %d requests, %v", statusCode, requests, err)
+		}
+	}
+}
+
+func TestCreateInChunksRejectsIncompleteResponse(t *testing.T) {
+	var matched, unmatched []int
+
+	created, err := createInChunks(context.Background(), IncomesType, testRows(5), 3, func(ctx context.Context, requests []int) ([]int, error) {
+		if len(requests) == 2 {
+			return requests[:1], nil
+		}
+		return requests, nil
+	}, func(row Row[int], dto int) error {
+		matched = append(matched, dto)
+		return nil
+	}, func(dto int) error {
+		unmatched = append(unmatched, dto)
+		return nil
+	})
+
+	if err == nil {
+		t.Fatal("expected an error of the incomplete response")
+	}
+
+	// the entities of the incomplete response are returned to be rolled back, but are not matched with the rows
+	if len(created) != 4 || !slices.Equal(matched, []int{0, 1, 2}) || !slices.Equal(unmatched, []int{3}) {
+		t.Errorf("expected 4 returned, 3 matched and 1 unmatched entities, got %v, %v and %v", created, matched, unmatched)
+	}
+}
@@ -0,0 +1,88 @@
+package migrator
+
+import (
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/model"
+	"github.com/VlasovArtem/hob-migration/src/parser"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// currencyColumn is the optional column of the incomes and payments with the currency of the amount.
+const currencyColumn = "Currency"
+
+// currencyConverter converts the amounts of the rows in other currencies to the currency of the file with the
+// exchange rates of the dates of the rows.
+type currencyConverter struct {
+	currency          string
+	exchangeRatesPath string
+	exchangeRates     *parser.ExchangeRates
+}
+
+func newCurrencyConverter(request EntityRequest) *currencyConverter {
+	return &currencyConverter{
+		currency:          parser.NormalizeCurrency(request.Currency),
+		exchangeRatesPath: request.ExchangeRates,
+	}
+}
+
+// load reads the exchange rates before the rows of the file are parsed.
+func (c *currencyConverter) load() error {
+	if c.exchangeRatesPath == "" {
+		return nil
+	}
+
+	exchangeRates, err := parser.ReadExchangeRates(c.exchangeRatesPath)
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to read exchange rates %s", c.exchangeRatesPath)
+	}
+
+	c.exchangeRates = exchangeRates
+
+	return nil
+}
+
+// convert returns the amount in the currency of the file, the description keeps the original amount and currency.
+func (c *currencyConverter) convert(sum model.Money, currency string, date string, description string) (model.Money, string, error) {
+	currency = parser.NormalizeCurrency(currency)
+
+	if currency == "" || currency == c.currency {
+		return sum, description, nil
+	}
+
+	if err := parser.VerifyCurrency(currency); err != nil {
+		return sum, description, parser.NewRowError(currencyColumn, currency, err.Error())
+	}
+
+	if c.currency == "" {
+		return sum, description, parser.NewRowError(currencyColumn, currency, "currency of the migrator file is not set")
+	}
+
+	if c.exchangeRates == nil {
+		return sum, description, parser.NewRowError(currencyColumn, currency, "exchange rates of the migrator file are not set")
+	}
+
+	// the date is already parsed to RFC3339
+	parsedDate, err := time.Parse(time.RFC3339, date)
+
+	if err != nil {
+		return sum, description, parser.NewRowError("Date", date, err.Error())
+	}
+
+	rate, err := c.exchangeRates.Rate(currency, parsedDate)
+
+	if err != nil {
+		return sum, description, parser.NewRowError(currencyColumn, currency, err.Error())
+	}
+
+	original := fmt.Sprintf("%s %s", sum.String(), currency)
+
+	if description == "" {
+		description = original
+	} else {
+		description = fmt.Sprintf("%s (%s)", description, original)
+	}
+
+	return model.NewMoney(sum.Mul(rate).Round(2)), description, nil
+}
@@ -0,0 +1,352 @@
+package migrator
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/client"
+	"github.com/VlasovArtem/hob-migration/src/model"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ManifestFileName is the migrator file written by Export next to the exported files, the entries set the format,
+// the delimiter, the decimal separator and the date layout of the exported files.
+const ManifestFileName = "migrator.json"
+
+// Export writes the groups, houses, providers, incomes and payments of the user from HOB to CSV files with the headers of the
+// migrators, and the migrator file of these files, so the directory can be migrated back with the migrate command.
+func Export(ctx context.Context, hobClient *client.HobClient, userId string, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create export directory %s", dir)
+	}
+
+	groups, err := hobClient.GetGroupsByUserId(ctx, userId)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to get groups")
+	}
+
+	houses, err := hobClient.GetHousesByUserId(ctx, userId)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to get houses")
+	}
+
+	providers, err := hobClient.GetProvidersByUserId(ctx, userId)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to get providers")
+	}
+
+	incomes, err := userIncomes(ctx, hobClient, houses, groups)
+
+	if err != nil {
+		return err
+	}
+
+	payments, err := userPayments(ctx, hobClient, houses, groups)
+
+	if err != nil {
+		return err
+	}
+
+	groupNames := make(map[uuid.UUID]string)
+
+	for _, group := range groups {
+		groupNames[group.Id] = group.Name
+	}
+
+	providerNames := make(map[uuid.UUID]string)
+
+	for _, provider := range providers {
+		providerNames[provider.Id] = provider.Name
+	}
+
+	houseIdentifiers := houseIdentifiersOf(houses)
+
+	files := map[string]string{
+		GroupsType:    filepath.Join(dir, GroupsType+".csv"),
+		HousesType:    filepath.Join(dir, HousesType+".csv"),
+		ProvidersType: filepath.Join(dir, ProvidersType+".csv"),
+		IncomesType:   filepath.Join(dir, IncomesType+".csv"),
+		PaymentsType:  filepath.Join(dir, PaymentsType+".csv"),
+	}
+
+	var groupLines [][]string
+
+	for _, group := range groups {
+		groupLines = append(groupLines, []string{group.Name})
+	}
+
+	var houseLines [][]string
+
+	for _, house := range houses {
+		houseLines = append(houseLines, []string{
+			houseIdentifiers[house.Id],
+			joinGroupNames(house.Groups, groupNames),
+			house.Name,
+			house.CountryCode,
+			house.City,
+			house.StreetLine1,
+			house.StreetLine2,
+		})
+	}
+
+	var providerLines [][]string
+
+	for _, provider := range providers {
+		providerLines = append(providerLines, []string{provider.Name, provider.Description, provider.Details})
+	}
+
+	var incomeLines [][]string
+
+	for _, income := range incomes {
+		var houseIdentifier string
+
+		// an income belongs either to groups or to a house
+		if len(income.Groups) == 0 {
+			houseIdentifier = houseIdentifiers[income.HouseId]
+		}
+
+		incomeLines = append(incomeLines, []string{
+			houseIdentifier,
+			joinGroupNames(income.Groups, groupNames),
+			income.Name,
+			income.Description,
+			income.Date.Format(time.RFC3339),
+			income.Sum.String(),
+			"",
+		})
+	}
+
+	var paymentLines [][]string
+
+	for _, payment := range payments {
+		var houseIdentifier string
+
+		// a payment belongs either to groups or to a house
+		if len(payment.Groups) == 0 {
+			houseIdentifier = houseIdentifiers[payment.HouseId]
+		}
+
+		paymentLines = append(paymentLines, []string{
+			houseIdentifier,
+			joinGroupNames(payment.Groups, groupNames),
+			payment.Name,
+			payment.Description,
+			payment.Date.Format(time.RFC3339),
+			payment.Sum.String(),
+			"",
+			providerNames[payment.ProviderId],
+		})
+	}
+
+	if err := writeCSV(files[GroupsType], groupHeader, groupLines); err != nil {
+		return err
+	}
+
+	if err := writeCSV(files[HousesType], houseHeader, houseLines); err != nil {
+		return err
+	}
+
+	if err := writeCSV(files[ProvidersType], providerHeader, providerLines); err != nil {
+		return err
+	}
+
+	if err := writeCSV(files[IncomesType], incomeHeader, incomeLines); err != nil {
+		return err
+	}
+
+	if err := writeCSV(files[PaymentsType], paymentHeader, paymentLines); err != nil {
+		return err
+	}
+
+	if err := writeManifest(filepath.Join(dir, ManifestFileName), files); err != nil {
+		return err
+	}
+
+	log.Info().Msgf("%d groups, %d houses, %d providers, %d incomes and %d payments exported to %s", len(groups), len(houses), len(providers), len(incomes), len(payments), dir)
+
+	return nil
+}
+
+// userIncomes returns the incomes of the houses and the groups, an income of several groups is returned once.
+func userIncomes(ctx context.Context, hobClient *client.HobClient, houses []model.HouseDto, groups []model.GroupDto) ([]model.IncomeDto, error) {
+	var incomes []model.IncomeDto
+	exported := make(map[uuid.UUID]bool)
+
+	add := func(income model.IncomeDto) {
+		if !exported[income.Id] {
+			exported[income.Id] = true
+			incomes = append(incomes, income)
+		}
+	}
+
+	for _, house := range houses {
+		houseIncomes, err := hobClient.GetIncomesByHouseId(ctx, house.Id)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get incomes of the house %s", house.Id)
+		}
+
+		for _, income := range houseIncomes {
+			add(income)
+		}
+	}
+
+	for _, group := range groups {
+		groupIncomes, err := hobClient.GetIncomesByGroupId(ctx, group.Id)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get incomes of the group %s", group.Id)
+		}
+
+		for _, income := range groupIncomes {
+			if len(income.Groups) == 0 {
+				income.Groups = []model.GroupDto{group}
+			}
+			add(income)
+		}
+	}
+
+	return incomes, nil
+}
+
+// userPayments returns the payments of the houses and the groups, a payment of several groups is returned once.
+func userPayments(ctx context.Context, hobClient *client.HobClient, houses []model.HouseDto, groups []model.GroupDto) ([]model.PaymentDto, error) {
+	var payments []model.PaymentDto
+	exported := make(map[uuid.UUID]bool)
+
+	add := func(payment model.PaymentDto) {
+		if !exported[payment.Id] {
+			exported[payment.Id] = true
+			payments = append(payments, payment)
+		}
+	}
+
+	for _, house := range houses {
+		housePayments, err := hobClient.GetPaymentsByHouseId(ctx, house.Id)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get payments of the house %s", house.Id)
+		}
+
+		for _, payment := range housePayments {
+			add(payment)
+		}
+	}
+
+	for _, group := range groups {
+		groupPayments, err := hobClient.GetPaymentsByGroupId(ctx, group.Id)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get payments of the group %s", group.Id)
+		}
+
+		for _, payment := range groupPayments {
+			if len(payment.Groups) == 0 {
+				payment.Groups = []model.GroupDto{group}
+			}
+			add(payment)
+		}
+	}
+
+	return payments, nil
+}
+
+// houseIdentifiersOf uses the name of a house as its House Identifier, the names that are used by several houses
+// get the number of the house as a suffix.
+func houseIdentifiersOf(houses []model.HouseDto) map[uuid.UUID]string {
+	identifiers := make(map[uuid.UUID]string)
+	used := make(map[string]bool)
+
+	for _, house := range houses {
+		identifier := house.Name
+
+		for number := 2; used[identifier]; number++ {
+			identifier = fmt.Sprintf("%s (%d)", house.Name, number)
+		}
+
+		used[identifier] = true
+		identifiers[house.Id] = identifier
+	}
+
+	return identifiers
+}
+
+func joinGroupNames(groups []model.GroupDto, groupNames map[uuid.UUID]string) string {
+	var names []string
+
+	for _, group := range groups {
+		name, ok := groupNames[group.Id]
+		if !ok {
+			name = group.Name
+		}
+		names = append(names, name)
+	}
+
+	return strings.Join(names, ",")
+}
+
+func writeCSV(path string, header []string, lines [][]string) error {
+	file, err := os.Create(path)
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", path)
+	}
+
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	if err := writer.WriteAll(lines); err != nil {
+		return errors.Wrapf(err, "failed to write %s", path)
+	}
+
+	return file.Close()
+}
+
+func writeManifest(path string, files map[string]string) error {
+	manifest := map[string]any{"version": ManifestVersion}
+
+	for entityType, filePath := range files {
+		absolutePath, err := filepath.Abs(filePath)
+
+		if err != nil {
+			return err
+		}
+
+		// the options are written explicitly, so the detection of the separators can not read 12.345 as 12345
+		request := EntityRequest{Path: absolutePath, Format: "csv", Delimiter: ","}
+
+		if entityType == IncomesType || entityType == PaymentsType {
+			request.DecimalSeparator = "."
+			request.DateLayout = time.RFC3339
+		}
+
+		manifest[entityType] = request
+	}
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, append(manifestBytes, '\n'), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %s", path)
+	}
+
+	return nil
+}
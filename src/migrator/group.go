@@ -1,95 +1,253 @@
 package migrator
 
 import (
-	"fmt"
+	"context"
 	"github.com/VlasovArtem/hob-migration/src/client"
 	"github.com/VlasovArtem/hob-migration/src/config"
+	"github.com/VlasovArtem/hob-migration/src/journal"
 	"github.com/VlasovArtem/hob-migration/src/model"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
 type GroupMigrator struct {
 	*BaseMigrator[map[string]model.GroupDto]
-	config *config.CMDConfig
-	client *client.HobClient
+	config    *config.CMDConfig
+	client    *client.HobClient
+	journal   *journal.Journal
+	reused    map[uuid.UUID]bool
+	batchSize int
 }
 
-func NewGroupMigrator(requestMigrator RequestMigrator, config *config.CMDConfig, hobClient *client.HobClient) *GroupMigrator {
+var groupHeader = []string{"Name"}
+
+func NewGroupMigrator(
+	requestMigrator RequestMigrator,
+	config *config.CMDConfig,
+	hobClient *client.HobClient,
+	journal *journal.Journal,
+) *GroupMigrator {
 	log.Info().Msg("Starting Group Migrator")
 
-	path, ok := requestMigrator.TypeToPathMap["groups"]
+	request, ok := requestMigrator.TypeToRequestMap[GroupsType]
 	if !ok {
 		log.Info().Msg("groups path not found")
 		return nil
 	}
 	migrator := &GroupMigrator{
-		client: hobClient,
-		config: config,
+		client:    hobClient,
+		config:    config,
+		journal:   journal,
+		reused:    make(map[uuid.UUID]bool),
+		batchSize: request.batchSize(config),
 	}
-	filePath := path
+	filePath := request.Path
 	migrator.BaseMigrator = &BaseMigrator[map[string]model.GroupDto]{
 		mappers: map[string]Mapper[map[string]model.GroupDto]{
 			"csv": &CSVMigrator[model.CreateGroupRequest, map[string]model.GroupDto]{
 				filePath: filePath,
-				header:   []string{"Name"},
+				format:   request.csvFormat(),
+				header:   groupHeader,
+				columns:  request.Columns,
 				parser:   migrator.parseCSVLine(),
 				mapper:   migrator.mapGroups,
 			},
+			"xlsx": &XLSXMigrator[model.CreateGroupRequest, map[string]model.GroupDto]{
+				filePath:    filePath,
+				sheet:       "Groups",
+				header:      groupHeader,
+				columns:     request.Columns,
+				dateColumns: nil,
+				parser:      migrator.parseCSVLine(),
+				mapper:      migrator.mapGroups,
+			},
+			"json": &JSONMigrator[groupRecord, model.CreateGroupRequest, map[string]model.GroupDto]{
+				filePath: filePath,
+				parser:   migrator.parseRecord,
+				mapper:   migrator.mapGroups,
+			},
 		},
-		filePath: filePath,
-		rollback: migrator.rollback,
+		filePath:   filePath,
+		format:     request.format(),
+		rollback:   migrator.rollback,
+		dryRun:     config.DryRun,
+		validation: requestMigrator.Validation,
+		entityType: GroupsType,
+		report:     requestMigrator.Report,
 	}
 
 	return migrator
 }
 
-func (g *GroupMigrator) mapGroups(requests []model.CreateGroupRequest) (map[string]model.GroupDto, error) {
-	var response = make(map[string]model.GroupDto)
+func (g *GroupMigrator) mapGroups(ctx context.Context, rows []Row[model.CreateGroupRequest]) (map[string]model.GroupDto, error) {
+	g.report.read(GroupsType, len(rows))
+
+	response, rows, err := resumeRows[model.CreateGroupRequest, model.GroupDto](g.journal, GroupsType, rows, groupKey, func(row Row[model.CreateGroupRequest], group model.GroupDto) {
+		g.report.entity(GroupsType, group.Id, row.Line, EntityRestored)
+	}, func(groups []model.GroupDto) {
+		g.rollback(ctx, byId(groups, func(group model.GroupDto) uuid.UUID { return group.Id }))
+	})
+
+	if err != nil {
+		return response, err
+	}
+
+	reused, rows, err := g.reuseExisting(ctx, rows)
+
+	if err != nil {
+		return response, err
+	}
+
+	for name, group := range reused {
+		response[name] = group
+	}
+
+	if len(rows) == 0 {
+		return response, nil
+	}
+
+	if g.config.DryRun {
+		requests := requestsOf(rows)
+
+		logDryRun(g.printRequests(), "groups", requests)
+
+		ownerId, _ := uuid.Parse(g.config.UserId)
+
+		for _, request := range requests {
+			response[request.Name] = model.GroupDto{
+				Id:      uuid.New(),
+				Name:    request.Name,
+				OwnerId: ownerId,
+			}
+		}
+
+		return response, nil
+	}
+
+	_, err = createInChunks(ctx, GroupsType, rows, g.batchSize, func(ctx context.Context, requests []model.CreateGroupRequest) ([]model.GroupDto, error) {
+		return g.client.CreateGroupBatch(ctx, model.CreateGroupBatchRequest{Groups: requests})
+	}, func(row Row[model.CreateGroupRequest], group model.GroupDto) error {
+		response[row.Request.Name] = group
+		g.report.entity(GroupsType, group.Id, row.Line, EntityCreated)
+
+		if err := g.journal.Created(GroupsType, group.Id, row.Request.Name, row.Line, group); err != nil {
+			log.Error().Err(err).Msg("Failed to journal created group")
+			return err
+		}
+		return nil
+	}, func(group model.GroupDto) error {
+		response[group.Id.String()] = group
+
+		if err := g.journal.Created(GroupsType, group.Id, "", 0, group); err != nil {
+			log.Error().Err(err).Msg("Failed to journal created group")
+			return err
+		}
+		return nil
+	})
+
+	return response, err
+}
+
+// reuseExisting matches the rows with the groups of the user that already exist in HOB by name.
+func (g *GroupMigrator) reuseExisting(ctx context.Context, rows []Row[model.CreateGroupRequest]) (map[string]model.GroupDto, []Row[model.CreateGroupRequest], error) {
+	reused := make(map[string]model.GroupDto)
+
+	if !g.config.ReuseExisting || len(rows) == 0 {
+		return reused, rows, nil
+	}
+
+	groups, err := g.client.GetGroupsByUserId(ctx, g.config.UserId)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get existing groups")
+		return nil, nil, err
+	}
 
-	if batchResponse, err := g.client.CreateGroupBatch(model.CreateGroupBatchRequest{Groups: requests}); err != nil {
-		return nil, err
-	} else {
-		for _, group := range batchResponse {
-			response[group.Name] = group
+	existing := make(map[string]model.GroupDto)
+
+	for _, group := range groups {
+		if _, ok := existing[group.Name]; !ok {
+			existing[group.Name] = group
 		}
+	}
 
-		log.Info().Msg(fmt.Sprintf("%d groups created", len(response)))
+	var pending []Row[model.CreateGroupRequest]
+
+	for _, row := range rows {
+		if group, ok := existing[row.Request.Name]; ok {
+			reused[row.Request.Name] = group
+			g.reused[group.Id] = true
+			g.report.entity(GroupsType, group.Id, row.Line, EntityReused)
+		} else {
+			pending = append(pending, row)
+		}
 	}
 
-	return response, nil
+	log.Info().Msgf("%d groups already exist and will be reused, %d groups to create", len(reused), len(pending))
+
+	return reused, pending, nil
+}
+
+func groupKey(row Row[model.CreateGroupRequest]) string {
+	return row.Request.Name
+}
+
+// groupRecord is a group as it is declared in the source file.
+type groupRecord struct {
+	Name string `json:"name"`
 }
 
 func (g *GroupMigrator) parseCSVLine() func(line []string, lineNumber int) (model.CreateGroupRequest, error) {
 	return func(line []string, lineNumber int) (model.CreateGroupRequest, error) {
-		request := model.CreateGroupRequest{
-			Name:    line[0],
-			OwnerId: g.config.UserId,
-		}
+		return g.parseRecord(groupRecord{Name: line[0]}, lineNumber)
+	}
+}
 
-		return request, nil
+func (g *GroupMigrator) parseRecord(record groupRecord, lineNumber int) (model.CreateGroupRequest, error) {
+	request := model.CreateGroupRequest{
+		Name:    record.Name,
+		OwnerId: g.config.UserId,
 	}
+
+	return request, nil
 }
 
-func (g *GroupMigrator) rollback(data map[string]model.GroupDto) {
+func (g *GroupMigrator) rollback(ctx context.Context, data map[string]model.GroupDto) (result RollbackResult) {
 	log.Info().Msg("Rolling back groups")
 	if len(data) == 0 {
 		log.Info().Msg("No groups to rollback")
 	}
 
 	for _, group := range data {
-		if err := g.client.DeleteGroupById(group.Id); err != nil {
+		if g.reused[group.Id] {
+			log.Info().Msgf("Group with id %s and name %s existed before the migration, skipped", group.Id, group.Name)
+			continue
+		}
+
+		err := g.client.DeleteGroupById(ctx, group.Id)
+
+		if err != nil {
 			log.Error().Err(err).Msgf("Failed to delete group with id %s and name %s", group.Id, group.Name)
 		} else {
 			log.Info().Msgf("Group with id %s and name %s deleted", group.Id, group.Name)
 		}
+
+		g.report.deleted(GroupsType, group.Id, err)
+		result.add(err)
+
+		if err := g.journal.Deleted(GroupsType, group.Id, err); err != nil {
+			log.Error().Err(err).Msgf("Failed to journal deleted group with id %s", group.Id)
+		}
 	}
+
+	return result
 }
 
-func (g *GroupMigrator) Migrate(rollbackOperation []func()) (map[string]model.GroupDto, []func()) {
+func (g *GroupMigrator) Migrate(ctx context.Context, rollbackOperations []RollbackOperation) (map[string]model.GroupDto, []RollbackOperation, error) {
 	if g != nil {
-		return g.BaseMigrator.Migrate(rollbackOperation)
+		return g.BaseMigrator.Migrate(ctx, rollbackOperations)
 	}
-	return nil, rollbackOperation
+	return nil, rollbackOperations, nil
 }
 
 func (g *GroupMigrator) GetBaseMigrator() *BaseMigrator[map[string]model.GroupDto] {
@@ -1,107 +1,306 @@
 package migrator
 
 import (
-	"fmt"
+	"context"
 	"github.com/VlasovArtem/hob-migration/src/client"
 	"github.com/VlasovArtem/hob-migration/src/config"
+	"github.com/VlasovArtem/hob-migration/src/journal"
 	"github.com/VlasovArtem/hob-migration/src/model"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
-	"strings"
+	"net/http"
 )
 
 type HouseMigrator struct {
 	*BaseMigrator[map[string]model.HouseDto]
-	client   *client.HobClient
-	groupMap map[string]model.GroupDto
-	config   *config.CMDConfig
+	client     *client.HobClient
+	groupMap   map[string]model.GroupDto
+	config     *config.CMDConfig
+	journal    *journal.Journal
+	reused     map[uuid.UUID]bool
+	references *References
+	batchSize  int
 }
 
+var houseHeader = []string{"House Identifier", "Groups", "Name", "Country", "City", "Address 1", "Address 2"}
+
 func NewHouseMigrator(
 	requestMigrator RequestMigrator,
 	config *config.CMDConfig,
 	hobClient *client.HobClient,
+	journal *journal.Journal,
 	groupMap map[string]model.GroupDto,
 ) *HouseMigrator {
 	log.Info().Msg("Starting House Migrator")
 
-	path, ok := requestMigrator.TypeToPathMap["houses"]
+	request, ok := requestMigrator.TypeToRequestMap[HousesType]
 	if !ok {
 		log.Info().Msg("houses path not found")
 		return nil
 	}
 	migrator := &HouseMigrator{
-		client:   hobClient,
-		groupMap: groupMap,
-		config:   config,
+		client:     hobClient,
+		groupMap:   groupMap,
+		config:     config,
+		journal:    journal,
+		reused:     make(map[uuid.UUID]bool),
+		references: requestMigrator.References,
+		batchSize:  request.batchSize(config),
 	}
-	filePath := path
+	filePath := request.Path
 	migrator.BaseMigrator = &BaseMigrator[map[string]model.HouseDto]{
 		mappers: map[string]Mapper[map[string]model.HouseDto]{
 			"csv": &CSVMigrator[MapCreateHouseRequest, map[string]model.HouseDto]{
 				filePath: filePath,
-				header:   []string{"House Identifier", "Groups", "Name", "Country", "City", "Address 1", "Address 2"},
+				format:   request.csvFormat(),
+				header:   houseHeader,
+				columns:  request.Columns,
 				parser:   migrator.parseCSVLine(),
 				mapper:   migrator.mapHouses,
 			},
+			"xlsx": &XLSXMigrator[MapCreateHouseRequest, map[string]model.HouseDto]{
+				filePath:    filePath,
+				sheet:       "Houses",
+				header:      houseHeader,
+				columns:     request.Columns,
+				dateColumns: nil,
+				parser:      migrator.parseCSVLine(),
+				mapper:      migrator.mapHouses,
+			},
+			"json": &JSONMigrator[houseRecord, MapCreateHouseRequest, map[string]model.HouseDto]{
+				filePath: filePath,
+				parser:   migrator.parseRecord,
+				mapper:   migrator.mapHouses,
+			},
 		},
-		filePath: filePath,
-		rollback: migrator.rollback,
+		filePath:   filePath,
+		format:     request.format(),
+		rollback:   migrator.rollback,
+		dryRun:     config.DryRun,
+		validation: requestMigrator.Validation,
+		entityType: HousesType,
+		report:     requestMigrator.Report,
 	}
 
 	return migrator
 }
 
-func (h *HouseMigrator) mapHouses(requests []MapCreateHouseRequest) (map[string]model.HouseDto, error) {
-	var response = make(map[string]model.HouseDto)
+func (h *HouseMigrator) mapHouses(ctx context.Context, rows []Row[MapCreateHouseRequest]) (map[string]model.HouseDto, error) {
+	h.report.read(HousesType, len(rows))
 
-	for _, request := range requests {
-		house, err := h.client.CreateHouse(request.request)
-		if err != nil {
-			log.Error().Err(err).Msg("Error creating house")
-			return nil, err
-		} else {
-			response[request.identifier] = house
+	response, rows, err := resumeRows[MapCreateHouseRequest, model.HouseDto](h.journal, HousesType, rows, houseKey, func(row Row[MapCreateHouseRequest], house model.HouseDto) {
+		h.report.entity(HousesType, house.Id, row.Line, EntityRestored)
+	}, func(houses []model.HouseDto) {
+		h.rollback(ctx, byId(houses, func(house model.HouseDto) uuid.UUID { return house.Id }))
+	})
+
+	if err != nil {
+		return response, err
+	}
+
+	reused, rows, err := h.reuseExisting(ctx, rows)
+
+	if err != nil {
+		return response, err
+	}
+
+	for identifier, house := range reused {
+		response[identifier] = house
+	}
+
+	if h.config.DryRun {
+		var houseRequests []model.CreateHouseRequest
+		userId, _ := uuid.Parse(h.config.UserId)
+
+		for _, row := range rows {
+			request := row.Request
+			houseRequests = append(houseRequests, request.request)
+			response[request.identifier] = model.HouseDto{
+				Id:          uuid.New(),
+				Name:        request.request.Name,
+				CountryCode: request.request.CountryCode,
+				City:        request.request.City,
+				StreetLine1: request.request.StreetLine1,
+				StreetLine2: request.request.StreetLine2,
+				UserId:      userId,
+			}
+		}
+
+		logDryRun(h.printRequests(), "houses", houseRequests)
+
+		return response, nil
+	}
+
+	houses, err := h.createHouses(ctx, rows)
+
+	for identifier, house := range houses {
+		response[identifier] = house
+	}
+
+	return response, err
+}
+
+// createHouses creates the houses with batch requests, or one by one if the batch endpoint is not available on HOB.
+// The houses of the chunks created before the batch endpoint responded with 404 or 405 are kept, only the rest of the
+// houses are created one by one.
+func (h *HouseMigrator) createHouses(ctx context.Context, rows []Row[MapCreateHouseRequest]) (map[string]model.HouseDto, error) {
+	houses := make(map[string]model.HouseDto)
+
+	created := func(row Row[MapCreateHouseRequest], house model.HouseDto) error {
+		houses[row.Request.identifier] = house
+		h.report.entity(HousesType, house.Id, row.Line, EntityCreated)
+
+		if err := h.journal.Created(HousesType, house.Id, row.Request.identifier, row.Line, house); err != nil {
+			log.Error().Err(err).Msg("Failed to journal created house")
+			return err
+		}
+		return nil
+	}
+
+	_, err := createInChunks(ctx, HousesType, rows, h.batchSize, func(ctx context.Context, requests []MapCreateHouseRequest) ([]model.HouseDto, error) {
+		batchRequest := model.CreateHouseBatchRequest{}
+
+		for _, request := range requests {
+			batchRequest.Houses = append(batchRequest.Houses, request.request)
+		}
+
+		return h.client.CreateHouseBatch(ctx, batchRequest)
+	}, created, func(house model.HouseDto) error {
+		houses[house.Id.String()] = house
+
+		if err := h.journal.Created(HousesType, house.Id, "", 0, house); err != nil {
+			log.Error().Err(err).Msg("Failed to journal created house")
+			return err
+		}
+		return nil
+	})
+
+	if client.IsStatus(err, http.StatusNotFound) || client.IsStatus(err, http.StatusMethodNotAllowed) {
+		var pending []Row[MapCreateHouseRequest]
+
+		for _, row := range rows {
+			if _, ok := houses[row.Request.identifier]; !ok {
+				pending = append(pending, row)
+			}
 		}
+
+		log.Warn().Err(err).Msgf("House batch endpoint is not available, %d houses are created one by one", len(pending))
+
+		return houses, createConcurrently(ctx, HousesType, pending, h.config.Concurrency, func(ctx context.Context, request MapCreateHouseRequest) (model.HouseDto, error) {
+			return h.client.CreateHouse(ctx, request.request)
+		}, created)
 	}
 
-	log.Info().Msg(fmt.Sprintf("%d houses created", len(response)))
+	return houses, err
+}
 
-	return response, nil
+// houseRecord is a house as it is declared in the source file.
+type houseRecord struct {
+	Identifier string   `json:"houseIdentifier"`
+	Groups     []string `json:"groups"`
+	Name       string   `json:"name"`
+	Country    string   `json:"country"`
+	City       string   `json:"city"`
+	Address1   string   `json:"address1"`
+	Address2   string   `json:"address2"`
 }
 
 func (h *HouseMigrator) parseCSVLine() func(line []string, lineNumber int) (MapCreateHouseRequest, error) {
 	return func(line []string, lineNumber int) (MapCreateHouseRequest, error) {
-		var groupIds []uuid.UUID
-
-		groupNames := line[1]
-
-		if len(groupNames) > 0 {
-			for _, groupName := range strings.Split(groupNames, ",") {
-				if dto, ok := h.groupMap[groupName]; !ok {
-					err := fmt.Errorf("group with name %s not found at the csv line %d", groupName, lineNumber)
-					log.Error().Err(err).Msg("Error reading groups")
-					return MapCreateHouseRequest{}, err
-				} else {
-					groupIds = append(groupIds, dto.Id)
-				}
-			}
+		return h.parseRecord(houseRecord{
+			Identifier: line[0],
+			Groups:     splitList(line[1]),
+			Name:       line[2],
+			Country:    line[3],
+			City:       line[4],
+			Address1:   line[5],
+			Address2:   line[6],
+		}, lineNumber)
+	}
+}
+
+func (h *HouseMigrator) parseRecord(record houseRecord, lineNumber int) (MapCreateHouseRequest, error) {
+	var groupIds []uuid.UUID
+
+	for _, groupName := range record.Groups {
+		if dto, err := h.references.resolveGroup(h.groupMap, groupName); err != nil {
+			return MapCreateHouseRequest{}, err
+		} else {
+			groupIds = append(groupIds, dto.Id)
 		}
-		request := model.CreateHouseRequest{
-			GroupIds:    groupIds,
-			Name:        line[2],
-			CountryCode: line[3],
-			City:        line[4],
-			StreetLine1: line[5],
-			StreetLine2: line[6],
-			UserId:      h.config.UserId,
+	}
+
+	request := model.CreateHouseRequest{
+		GroupIds:    groupIds,
+		Name:        record.Name,
+		CountryCode: record.Country,
+		City:        record.City,
+		StreetLine1: record.Address1,
+		StreetLine2: record.Address2,
+		UserId:      h.config.UserId,
+	}
+
+	return MapCreateHouseRequest{
+		identifier: record.Identifier,
+		request:    request,
+	}, nil
+}
+
+// reuseExisting matches the rows with the houses of the user that already exist in HOB by name and address.
+func (h *HouseMigrator) reuseExisting(ctx context.Context, rows []Row[MapCreateHouseRequest]) (map[string]model.HouseDto, []Row[MapCreateHouseRequest], error) {
+	reused := make(map[string]model.HouseDto)
+
+	if !h.config.ReuseExisting || len(rows) == 0 {
+		return reused, rows, nil
+	}
+
+	houses, err := h.client.GetHousesByUserId(ctx, h.config.UserId)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get existing houses")
+		return nil, nil, err
+	}
+
+	existing := make(map[houseAddress]model.HouseDto)
+
+	for _, house := range houses {
+		address := houseAddress{house.Name, house.CountryCode, house.City, house.StreetLine1, house.StreetLine2}
+
+		if _, ok := existing[address]; !ok {
+			existing[address] = house
 		}
+	}
 
-		return MapCreateHouseRequest{
-			identifier: line[0],
-			request:    request,
-		}, nil
+	var pending []Row[MapCreateHouseRequest]
+
+	for _, row := range rows {
+		request := row.Request.request
+		address := houseAddress{request.Name, request.CountryCode, request.City, request.StreetLine1, request.StreetLine2}
+
+		if house, ok := existing[address]; ok {
+			reused[row.Request.identifier] = house
+			h.reused[house.Id] = true
+			h.report.entity(HousesType, house.Id, row.Line, EntityReused)
+		} else {
+			pending = append(pending, row)
+		}
 	}
+
+	log.Info().Msgf("%d houses already exist and will be reused, %d houses to create", len(reused), len(pending))
+
+	return reused, pending, nil
+}
+
+type houseAddress struct {
+	name        string
+	countryCode string
+	city        string
+	streetLine1 string
+	streetLine2 string
+}
+
+func houseKey(row Row[MapCreateHouseRequest]) string {
+	return row.Request.identifier
 }
 
 type MapCreateHouseRequest struct {
@@ -109,24 +308,40 @@ type MapCreateHouseRequest struct {
 	request    model.CreateHouseRequest
 }
 
-func (h *HouseMigrator) rollback(data map[string]model.HouseDto) {
+func (h *HouseMigrator) rollback(ctx context.Context, data map[string]model.HouseDto) (result RollbackResult) {
 	log.Info().Msg("Rolling back houses")
 	if len(data) == 0 {
 		log.Info().Msg("No houses to rollback")
 	}
 
 	for _, house := range data {
-		if err := h.client.DeleteHouseById(house.Id); err != nil {
+		if h.reused[house.Id] {
+			log.Info().Msgf("House with id %s and name %s existed before the migration, skipped", house.Id, house.Name)
+			continue
+		}
+
+		err := h.client.DeleteHouseById(ctx, house.Id)
+
+		if err != nil {
 			log.Error().Err(err).Msgf("Failed to delete house with id %s and name %s", house.Id, house.Name)
 		} else {
 			log.Info().Msgf("House with id %s and name %s deleted", house.Id, house.Name)
 		}
+
+		h.report.deleted(HousesType, house.Id, err)
+		result.add(err)
+
+		if err := h.journal.Deleted(HousesType, house.Id, err); err != nil {
+			log.Error().Err(err).Msgf("Failed to journal deleted house with id %s", house.Id)
+		}
 	}
+
+	return result
 }
 
-func (h *HouseMigrator) Migrate(rollbackOperation []func()) (map[string]model.HouseDto, []func()) {
+func (h *HouseMigrator) Migrate(ctx context.Context, rollbackOperations []RollbackOperation) (map[string]model.HouseDto, []RollbackOperation, error) {
 	if h != nil {
-		return h.BaseMigrator.Migrate(rollbackOperation)
+		return h.BaseMigrator.Migrate(ctx, rollbackOperations)
 	}
-	return nil, rollbackOperation
+	return nil, rollbackOperations, nil
 }
@@ -1,148 +1,298 @@
 package migrator
 
 import (
-	"fmt"
+	"context"
 	"github.com/VlasovArtem/hob-migration/src/client"
+	"github.com/VlasovArtem/hob-migration/src/config"
+	"github.com/VlasovArtem/hob-migration/src/journal"
 	"github.com/VlasovArtem/hob-migration/src/model"
-	"github.com/pkg/errors"
+	"github.com/VlasovArtem/hob-migration/src/parser"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
-	"strconv"
 	"strings"
+	"time"
 )
 
 type IncomeMigrator struct {
 	*BaseMigrator[[]model.IncomeDto]
-	client   *client.HobClient
-	houseMap map[string]model.HouseDto
-	groupMap map[string]model.GroupDto
+	client     *client.HobClient
+	houseMap   map[string]model.HouseDto
+	groupMap   map[string]model.GroupDto
+	references *References
+	config     *config.CMDConfig
+	journal    *journal.Journal
+	batchSize  int
+	dateFormat parser.DateFormat
+	converter  *currencyConverter
 }
 
+// groupsColumn is the column of the houses, incomes and payments with the names of the groups.
+const groupsColumn = "Groups"
+
+var incomeHeader = []string{"House Identifier", groupsColumn, "Name", "Description", "Date", "Sum", currencyColumn}
+
 func NewIncomeMigrator(
 	requestMigrator RequestMigrator,
+	config *config.CMDConfig,
 	hobClient *client.HobClient,
+	journal *journal.Journal,
 	houseMap map[string]model.HouseDto,
 	groupMap map[string]model.GroupDto,
 ) *IncomeMigrator {
 	log.Info().Msg("Starting Income Migrator")
 
-	path, ok := requestMigrator.TypeToPathMap["incomes"]
+	request, ok := requestMigrator.TypeToRequestMap[IncomesType]
 	if !ok {
 		log.Info().Msg("income path not found")
 		return nil
 	}
 	migrator := &IncomeMigrator{
-		client:   hobClient,
-		houseMap: houseMap,
-		groupMap: groupMap,
+		client:     hobClient,
+		houseMap:   houseMap,
+		groupMap:   groupMap,
+		references: requestMigrator.References,
+		config:     config,
+		journal:    journal,
+		batchSize:  request.batchSize(config),
+		dateFormat: request.dateFormat(),
+		converter:  newCurrencyConverter(request),
 	}
-	filePath := path
+	filePath := request.Path
 	migrator.BaseMigrator = &BaseMigrator[[]model.IncomeDto]{
 		mappers: map[string]Mapper[[]model.IncomeDto]{
 			"csv": &CSVMigrator[model.CreateIncomeRequest, []model.IncomeDto]{
 				filePath: filePath,
-				header:   []string{"House Identifier", "Groups", "Name", "Description", "Date", "Sum"},
-				parser:   migrator.parseCSVLine(),
+				format:   request.csvFormat(),
+				header:   incomeHeader,
+				columns:  withOptionalColumns(request.Columns, currencyColumn),
+				parser:   migrator.parseCSVLine(request.amountFormat()),
+				mapper:   migrator.mapIncomes,
+			},
+			"xlsx": &XLSXMigrator[model.CreateIncomeRequest, []model.IncomeDto]{
+				filePath:    filePath,
+				sheet:       "Incomes",
+				header:      incomeHeader,
+				columns:     withOptionalColumns(request.Columns, currencyColumn),
+				dateColumns: []string{"Date"},
+				parser:      migrator.parseCSVLine(request.xlsxAmountFormat()),
+				mapper:      migrator.mapIncomes,
+			},
+			"ofx": &OFXMigrator[model.CreateIncomeRequest, []model.IncomeDto]{
+				filePath: filePath,
+				filter:   func(transaction parser.OFXTransaction) bool { return transaction.Amount.IsPositive() },
+				parser:   migrator.parseOFXTransaction(request.House),
+				mapper:   migrator.mapIncomes,
+			},
+			"json": &JSONMigrator[incomeRecord, model.CreateIncomeRequest, []model.IncomeDto]{
+				filePath: filePath,
+				parser:   migrator.parseRecord,
 				mapper:   migrator.mapIncomes,
 			},
 		},
-		filePath: filePath,
-		rollback: migrator.rollback,
+		filePath:   filePath,
+		format:     request.format(),
+		rollback:   migrator.rollback,
+		dryRun:     config.DryRun,
+		validation: requestMigrator.Validation,
+		entityType: IncomesType,
+		report:     requestMigrator.Report,
+		prepare:    migrator.converter.load,
 	}
 
 	return migrator
 }
 
-func (i *IncomeMigrator) mapIncomes(requests []model.CreateIncomeRequest) (responses []model.IncomeDto, err error) {
-	request := model.CreateIncomeBatchRequest{Incomes: requests}
+func (i *IncomeMigrator) mapIncomes(ctx context.Context, rows []Row[model.CreateIncomeRequest]) (responses []model.IncomeDto, err error) {
+	i.report.read(IncomesType, len(rows))
+
+	requests := requestsOf(rows)
+
+	if i.config.DryRun {
+		logDryRun(i.printRequests(), "incomes", requests)
+
+		for _, request := range requests {
+			responses = append(responses, model.IncomeDto{
+				Id:          uuid.New(),
+				Name:        request.Name,
+				Description: request.Description,
+				Sum:         request.Sum,
+			})
+		}
+
+		return responses, nil
+	}
+
+	keys, err := contentKeys(rows)
 
-	if response, err := i.client.CreateIncomeBatch(request); err != nil {
-		log.Error().Err(err).Msg("failed to create income batch")
+	if err != nil {
 		return nil, err
-	} else {
-		log.Info().Msg(fmt.Sprintf("%d incomes created", len(response)))
-		return response, nil
 	}
+
+	// the restored incomes are added in the order of the rows, so the report and the rollback are the same in every run
+	_, rows, err = resumeRows[model.CreateIncomeRequest, model.IncomeDto](i.journal, IncomesType, rows, func(row Row[model.CreateIncomeRequest]) string { return keys[row.Line] }, func(row Row[model.CreateIncomeRequest], income model.IncomeDto) {
+		responses = append(responses, income)
+		i.report.entity(IncomesType, income.Id, row.Line, EntityRestored)
+	}, func(incomes []model.IncomeDto) {
+		i.rollback(ctx, incomes)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return responses, nil
+	}
+
+	created, err := createInChunks(ctx, IncomesType, rows, i.batchSize, func(ctx context.Context, requests []model.CreateIncomeRequest) ([]model.IncomeDto, error) {
+		return i.client.CreateIncomeBatch(ctx, model.CreateIncomeBatchRequest{Incomes: requests})
+	}, func(row Row[model.CreateIncomeRequest], income model.IncomeDto) error {
+		i.report.entity(IncomesType, income.Id, row.Line, EntityCreated)
+
+		if err := i.journal.Created(IncomesType, income.Id, keys[row.Line], row.Line, income); err != nil {
+			log.Error().Err(err).Msg("Failed to journal created income")
+			return err
+		}
+		return nil
+	}, func(income model.IncomeDto) error {
+		if err := i.journal.Created(IncomesType, income.Id, "", 0, income); err != nil {
+			log.Error().Err(err).Msg("Failed to journal created income")
+			return err
+		}
+		return nil
+	})
+
+	return append(responses, created...), err
+}
+
+// incomeRecord is an income as it is declared in the source file.
+type incomeRecord struct {
+	HouseIdentifier string      `json:"houseIdentifier"`
+	Groups          []string    `json:"groups"`
+	Name            string      `json:"name"`
+	Description     string      `json:"description"`
+	Date            string      `json:"date"`
+	Sum             model.Money `json:"sum"`
+	Currency        string      `json:"currency"`
 }
 
-func (i *IncomeMigrator) parseCSVLine() func(line []string, lineNumber int) (model.CreateIncomeRequest, error) {
+func (i *IncomeMigrator) parseCSVLine(amountFormat parser.AmountFormat) func(line []string, lineNumber int) (model.CreateIncomeRequest, error) {
 	return func(line []string, lineNumber int) (model.CreateIncomeRequest, error) {
-		sum, err := strconv.ParseFloat(line[5], 2)
+		sum, err := parser.ParseAmount(line[5], amountFormat)
 
 		if err != nil {
-			log.Error().Msgf("sum not valid float %s at the csv line %d", line[3], lineNumber)
-			return model.CreateIncomeRequest{}, err
+			return model.CreateIncomeRequest{}, parser.NewRowError("Sum", line[5], err.Error())
 		}
 
-		groupIds, err := func() ([]string, error) {
-			groups := line[1]
-			if len(groups) == 0 {
-				return nil, nil
-			}
-			var groupIds []string
-			for _, group := range strings.Split(groups, ",") {
-				trimGroup := strings.Trim(group, " ")
-				if dto, ok := i.groupMap[trimGroup]; ok {
-					groupIds = append(groupIds, dto.Id.String())
-				} else {
-					err = errors.Errorf("group with name %s not found at the csv line %d", trimGroup, lineNumber)
-					return nil, err
-				}
-			}
-			return groupIds, nil
-		}()
+		return i.parseRecord(incomeRecord{
+			HouseIdentifier: line[0],
+			Groups:          splitList(line[1]),
+			Name:            line[2],
+			Description:     strings.Replace(line[3], ";", ",", -1),
+			Date:            line[4],
+			Sum:             sum,
+			Currency:        line[6],
+		}, lineNumber)
+	}
+}
 
-		if err != nil {
-			return model.CreateIncomeRequest{}, err
+// parseOFXTransaction creates the income of the house from the migrator file for a transaction of an OFX file.
+func (i *IncomeMigrator) parseOFXTransaction(house string) func(transaction parser.OFXTransaction, lineNumber int) (model.CreateIncomeRequest, error) {
+	return func(transaction parser.OFXTransaction, lineNumber int) (model.CreateIncomeRequest, error) {
+		if house == "" {
+			return model.CreateIncomeRequest{}, parser.NewRowError("House Identifier", house, "house of the OFX file is not set in the migrator file")
 		}
 
-		houseId, err := func() (*string, error) {
-			if len(groupIds) == 0 {
-				if dto, ok := i.houseMap[line[0]]; ok {
-					id := dto.Id.String()
-					return &id, nil
-				} else {
-					err = errors.Errorf("house with name %s not found at the csv line %d", line[0], lineNumber)
-					return nil, err
-				}
-			}
-			return nil, nil
-		}()
+		name, description := transaction.Name, transaction.Memo
+		if name == "" {
+			name, description = transaction.Memo, ""
+		}
 
-		if err != nil {
+		return i.parseRecord(incomeRecord{
+			HouseIdentifier: house,
+			Name:            name,
+			Description:     description,
+			Date:            transaction.Date.Format(time.RFC3339),
+			Sum:             transaction.Amount,
+		}, lineNumber)
+	}
+}
+
+func (i *IncomeMigrator) parseRecord(record incomeRecord, lineNumber int) (model.CreateIncomeRequest, error) {
+	var groupIds []string
+
+	for _, group := range record.Groups {
+		if dto, err := i.references.resolveGroup(i.groupMap, group); err != nil {
 			return model.CreateIncomeRequest{}, err
+		} else {
+			groupIds = append(groupIds, dto.Id.String())
 		}
+	}
+
+	var houseId *string
 
-		request := model.CreateIncomeRequest{
-			Name:        line[2],
-			Description: strings.Replace(line[3], ";", ",", -1),
-			Date:        line[4],
-			Sum:         float32(sum),
-			HouseId:     houseId,
-			GroupIds:    groupIds,
+	if len(groupIds) == 0 {
+		if dto, err := i.references.resolveHouse(i.houseMap, record.HouseIdentifier); err != nil {
+			return model.CreateIncomeRequest{}, err
+		} else {
+			id := dto.Id.String()
+			houseId = &id
 		}
+	}
+
+	date, err := parser.ParseDate(record.Date, i.dateFormat)
+
+	if err != nil {
+		return model.CreateIncomeRequest{}, parser.NewRowError("Date", record.Date, err.Error())
+	}
+
+	sum, description, err := i.converter.convert(record.Sum, record.Currency, date, record.Description)
+
+	if err != nil {
+		return model.CreateIncomeRequest{}, err
+	}
 
-		return request, nil
+	request := model.CreateIncomeRequest{
+		Name:        record.Name,
+		Description: description,
+		Date:        date,
+		Sum:         sum,
+		HouseId:     houseId,
+		GroupIds:    groupIds,
 	}
+
+	return request, nil
 }
 
-func (i *IncomeMigrator) rollback(data []model.IncomeDto) {
+func (i *IncomeMigrator) rollback(ctx context.Context, data []model.IncomeDto) (result RollbackResult) {
 	log.Info().Msg("Rolling back incomes")
 	if len(data) == 0 {
 		log.Info().Msg("No incomes to rollback")
 	}
 
 	for _, income := range data {
-		if err := i.client.DeleteIncomeById(income.Id); err != nil {
+		err := i.client.DeleteIncomeById(ctx, income.Id)
+
+		if err != nil {
 			log.Error().Err(err).Msgf("Failed to delete income with id %s and name %s", income.Id, income.Name)
 		} else {
 			log.Info().Msgf("Income with id %s and name %s deleted", income.Id, income.Name)
 		}
+
+		i.report.deleted(IncomesType, income.Id, err)
+		result.add(err)
+
+		if err := i.journal.Deleted(IncomesType, income.Id, err); err != nil {
+			log.Error().Err(err).Msgf("Failed to journal deleted income with id %s", income.Id)
+		}
 	}
+
+	return result
 }
 
-func (i *IncomeMigrator) Migrate(rollbackOperation []func()) ([]model.IncomeDto, []func()) {
+func (i *IncomeMigrator) Migrate(ctx context.Context, rollbackOperations []RollbackOperation) ([]model.IncomeDto, []RollbackOperation, error) {
 	if i != nil {
-		return i.BaseMigrator.Migrate(rollbackOperation)
+		return i.BaseMigrator.Migrate(ctx, rollbackOperations)
 	}
-	return nil, rollbackOperation
+	return nil, rollbackOperations, nil
 }
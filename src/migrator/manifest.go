@@ -0,0 +1,274 @@
+package migrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/parser"
+	"github.com/VlasovArtem/hob-migration/src/validator"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ManifestVersion is the latest version of the migrator file. The version 1 maps the entity types to the paths, the
+// version 2 adds the options of the files.
+const ManifestVersion = 2
+
+var EntityTypes = []string{GroupsType, HousesType, ProvidersType, IncomesType, PaymentsType}
+
+// ReadManifest reads the migrator file, a JSON or YAML (.yaml, .yml) object with the entity types as the keys. The
+// environment variables of the paths are expanded, an unknown entity type or option is an error.
+func ReadManifest(path string) (map[string]EntityRequest, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read migrator file %s", path)
+	}
+
+	if extension := strings.ToLower(filepath.Ext(path)); extension == ".yaml" || extension == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, errors.Wrapf(err, "failed to read migrator file %s", path)
+		}
+	}
+
+	manifest, err := ParseManifest(data)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid migrator file %s", path)
+	}
+
+	return manifest, nil
+}
+
+// ParseManifest parses the JSON migrator file.
+func ParseManifest(data []byte) (map[string]EntityRequest, error) {
+	var entries map[string]json.RawMessage
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	manifest := make(map[string]EntityRequest)
+
+	keys := maps.Keys(entries)
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		if key == "version" {
+			var version int
+			if err := json.Unmarshal(entries[key], &version); err != nil || version < 1 || version > ManifestVersion {
+				return nil, fmt.Errorf("version %s is not supported, the latest version is %d", entries[key], ManifestVersion)
+			}
+			continue
+		}
+
+		if !slices.Contains(EntityTypes, key) {
+			return nil, fmt.Errorf("unknown entity type %s, expected %s", key, strings.Join(EntityTypes, ", "))
+		}
+
+		var request EntityRequest
+
+		if err := json.Unmarshal(entries[key], &request); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) && typeErr.Field != "" {
+				return nil, fmt.Errorf("invalid %s: %s is a %s, expected a %s", key, typeErr.Field, typeErr.Value, typeErr.Type)
+			}
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+
+		path, err := expandEnv(request.Path)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+
+		exchangeRates, err := expandEnv(request.ExchangeRates)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+
+		request.Path = path
+		request.ExchangeRates = exchangeRates
+		manifest[key] = request
+	}
+
+	return manifest, VerifyManifest(manifest)
+}
+
+// VerifyManifest returns an error if an entity type or an option of the files is invalid.
+func VerifyManifest(manifest map[string]EntityRequest) error {
+	if len(manifest) == 0 {
+		return fmt.Errorf("no files, expected %s", strings.Join(EntityTypes, ", "))
+	}
+
+	keys := maps.Keys(manifest)
+	slices.Sort(keys)
+
+	for _, entityType := range keys {
+		if !slices.Contains(EntityTypes, entityType) {
+			return fmt.Errorf("unknown entity type %s, expected %s", entityType, strings.Join(EntityTypes, ", "))
+		}
+
+		request := manifest[entityType]
+
+		if err := request.verify(); err != nil {
+			return fmt.Errorf("invalid %s: %w", entityType, err)
+		}
+
+		if (request.Currency != "" || request.ExchangeRates != "") && entityType != IncomesType && entityType != PaymentsType {
+			return fmt.Errorf("invalid %s: currency and exchange rates are options of incomes and payments", entityType)
+		}
+	}
+
+	return nil
+}
+
+func (e EntityRequest) verify() error {
+	if e.Path == "" {
+		return errors.New("path is empty")
+	}
+
+	if err := validator.VerifyFormatIsValid(e.format()); err != nil {
+		return err
+	}
+
+	if (e.Delimiter != "" || e.Encoding != "") && e.format() != "csv" {
+		return fmt.Errorf("delimiter and encoding are options of csv files, the format is %s", e.format())
+	}
+
+	if e.House != "" && e.format() != "ofx" {
+		return fmt.Errorf("house is an option of ofx files, the format is %s", e.format())
+	}
+
+	if utf8.RuneCountInString(e.Delimiter) > 1 {
+		return fmt.Errorf("delimiter %q is not a single character", e.Delimiter)
+	}
+
+	if e.Encoding != "" {
+		if err := parser.VerifyEncoding(e.Encoding); err != nil {
+			return err
+		}
+	}
+
+	for _, layout := range e.dateLayouts() {
+		if err := parser.VerifyDateLayout(layout); err != nil {
+			return err
+		}
+	}
+
+	if _, err := time.LoadLocation(e.TimeZone); err != nil {
+		return fmt.Errorf("time zone %s is not supported", e.TimeZone)
+	}
+
+	if e.Currency != "" {
+		if err := parser.VerifyCurrency(parser.NormalizeCurrency(e.Currency)); err != nil {
+			return err
+		}
+	}
+
+	if e.ExchangeRates != "" {
+		if e.Currency == "" {
+			return errors.New("exchange rates require the currency the amounts are converted to")
+		}
+
+		if err := validator.VerifyFilePathExists(e.ExchangeRates); err != nil {
+			return err
+		}
+	}
+
+	if e.BatchSize < 0 {
+		return fmt.Errorf("batch size %d is negative", e.BatchSize)
+	}
+
+	return nil
+}
+
+// expandEnv expands the $NAME and ${NAME} environment variables of the path, an unset variable is an error.
+func expandEnv(path string) (string, error) {
+	var unset []string
+
+	expanded := os.Expand(path, func(name string) string {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			unset = append(unset, name)
+		}
+		return value
+	})
+
+	if len(unset) != 0 {
+		return "", fmt.Errorf("environment variable %s of the path %s is not set", strings.Join(unset, ", "), path)
+	}
+
+	return expanded, nil
+}
+
+// yamlToJSON converts the YAML migrator file to JSON, so both are parsed the same way. The scalars keep the text of
+// the file, so an unquoted option like the date layout 2006-01-02 is not converted to a timestamp.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var document yaml.Node
+
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	value, err := yamlValue(&document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(value)
+}
+
+// yamlValue returns the value of the YAML node, the scalars other than numbers, booleans and nulls are strings.
+func yamlValue(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlValue(node.Content[0])
+	case yaml.AliasNode:
+		return yamlValue(node.Alias)
+	case yaml.SequenceNode:
+		values := make([]any, 0, len(node.Content))
+
+		for _, item := range node.Content {
+			value, err := yamlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+
+		return values, nil
+	case yaml.MappingNode:
+		values := make(map[string]any)
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value, err := yamlValue(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			values[node.Content[i].Value] = value
+		}
+
+		return values, nil
+	}
+
+	switch node.ShortTag() {
+	case "!!int", "!!float", "!!bool", "!!null":
+		var value any
+		err := node.Decode(&value)
+		return value, err
+	}
+
+	return node.Value, nil
+}
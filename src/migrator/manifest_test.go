@@ -0,0 +1,57 @@
+package migrator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readTestManifest(t *testing.T, content string) (map[string]EntityRequest, error) {
+	path := filepath.Join(t.TempDir(), "migrator.yaml")
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return ReadManifest(path)
+}
+
+func TestReadManifestKeepsUnquotedYAMLOptions(t *testing.T) {
+	manifest, err := readTestManifest(t, `
+version: 2
+payments:
+  path: payments.csv
+  delimiter: ;
+  dateLayout: 2006-01-02
+  dateLayouts: [2006-01-02, 02.01.2006]
+  decimalSeparator: ","
+  batchSize: 100
+`)
+
+	if err != nil {
+		t.Fatalf("expected a valid migrator file, got %v", err)
+	}
+
+	payments := manifest[PaymentsType]
+
+	if payments.DateLayout != "2006-01-02" || payments.DateLayouts[0] != "2006-01-02" || payments.DateLayouts[1] != "02.01.2006" {
+		t.Errorf("expected the date layouts as in the file, got %q and %q", payments.DateLayout, payments.DateLayouts)
+	}
+
+	if payments.Delimiter != ";" || payments.DecimalSeparator != "," || payments.BatchSize != 100 {
+		t.Errorf("expected the options as in the file, got %+v", payments)
+	}
+}
+
+func TestReadManifestRejectsNumberOfStringOption(t *testing.T) {
+	_, err := readTestManifest(t, `
+payments:
+  path: payments.csv
+  thousandsSeparator: 1
+`)
+
+	if err == nil || !strings.Contains(err.Error(), "thousandsSeparator is a number, expected a string") {
+		t.Errorf("expected an error of the number, got %v", err)
+	}
+}
@@ -1,123 +1,316 @@
 package migrator
 
 import (
-	"fmt"
+	"context"
 	"github.com/VlasovArtem/hob-migration/src/client"
 	"github.com/VlasovArtem/hob-migration/src/config"
+	"github.com/VlasovArtem/hob-migration/src/journal"
 	"github.com/VlasovArtem/hob-migration/src/model"
+	"github.com/VlasovArtem/hob-migration/src/parser"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
-	"strconv"
 	"strings"
+	"time"
 )
 
 type PaymentMigrator struct {
 	*BaseMigrator[[]model.PaymentDto]
-	client   *client.HobClient
-	houseMap map[string]model.HouseDto
-	groupMap map[string]model.GroupDto
-	config   *config.CMDConfig
+	client      *client.HobClient
+	houseMap    map[string]model.HouseDto
+	groupMap    map[string]model.GroupDto
+	providerMap map[string]model.ProviderDto
+	references  *References
+	config      *config.CMDConfig
+	journal     *journal.Journal
+	batchSize   int
+	dateFormat  parser.DateFormat
+	converter   *currencyConverter
 }
 
+// providerColumn is the optional column of the payments with the name of the provider.
+const providerColumn = "Provider"
+
+var paymentHeader = []string{"House Identifier", groupsColumn, "Name", "Description", "Date", "Sum", currencyColumn, providerColumn}
+
 func NewPaymentMigrator(
 	requestMigrator RequestMigrator,
 	config *config.CMDConfig,
 	hobClient *client.HobClient,
+	journal *journal.Journal,
 	houseMap map[string]model.HouseDto,
+	groupMap map[string]model.GroupDto,
+	providerMap map[string]model.ProviderDto,
 ) *PaymentMigrator {
 	log.Info().Msg("Starting Payment Migrator")
 
-	path, ok := requestMigrator.TypeToPathMap["payments"]
+	request, ok := requestMigrator.TypeToRequestMap[PaymentsType]
 	if !ok {
 		log.Info().Msg("payments path not found")
 		return nil
 	}
 	migrator := &PaymentMigrator{
-		client:   hobClient,
-		houseMap: houseMap,
-		config:   config,
+		client:      hobClient,
+		houseMap:    houseMap,
+		groupMap:    groupMap,
+		providerMap: providerMap,
+		references:  requestMigrator.References,
+		config:      config,
+		journal:     journal,
+		batchSize:   request.batchSize(config),
+		dateFormat:  request.dateFormat(),
+		converter:   newCurrencyConverter(request),
 	}
-	filePath := path
+	filePath := request.Path
 	migrator.BaseMigrator = &BaseMigrator[[]model.PaymentDto]{
 		mappers: map[string]Mapper[[]model.PaymentDto]{
 			"csv": &CSVMigrator[model.CreatePaymentRequest, []model.PaymentDto]{
 				filePath: filePath,
-				header:   []string{"House Identifier", "Name", "Description", "Date", "Sum"},
-				parser:   migrator.parseCSVLine(),
+				format:   request.csvFormat(),
+				header:   paymentHeader,
+				columns:  withOptionalColumns(request.Columns, groupsColumn, currencyColumn, providerColumn),
+				parser:   migrator.parseCSVLine(request.amountFormat()),
+				mapper:   migrator.mapPayments,
+			},
+			"xlsx": &XLSXMigrator[model.CreatePaymentRequest, []model.PaymentDto]{
+				filePath:    filePath,
+				sheet:       "Payments",
+				header:      paymentHeader,
+				columns:     withOptionalColumns(request.Columns, groupsColumn, currencyColumn, providerColumn),
+				dateColumns: []string{"Date"},
+				parser:      migrator.parseCSVLine(request.xlsxAmountFormat()),
+				mapper:      migrator.mapPayments,
+			},
+			"ofx": &OFXMigrator[model.CreatePaymentRequest, []model.PaymentDto]{
+				filePath: filePath,
+				filter:   func(transaction parser.OFXTransaction) bool { return transaction.Amount.IsNegative() },
+				parser:   migrator.parseOFXTransaction(request.House),
+				mapper:   migrator.mapPayments,
+			},
+			"json": &JSONMigrator[paymentRecord, model.CreatePaymentRequest, []model.PaymentDto]{
+				filePath: filePath,
+				parser:   migrator.parseRecord,
 				mapper:   migrator.mapPayments,
 			},
 		},
-		filePath: filePath,
-		rollback: migrator.rollback,
+		filePath:   filePath,
+		format:     request.format(),
+		rollback:   migrator.rollback,
+		dryRun:     config.DryRun,
+		validation: requestMigrator.Validation,
+		entityType: PaymentsType,
+		report:     requestMigrator.Report,
+		prepare:    migrator.converter.load,
 	}
 
 	return migrator
 }
 
-func (p *PaymentMigrator) mapPayments(requests []model.CreatePaymentRequest) (responses []model.PaymentDto, err error) {
-	request := model.CreatePaymentBatchRequest{Payments: requests}
+func (p *PaymentMigrator) mapPayments(ctx context.Context, rows []Row[model.CreatePaymentRequest]) (responses []model.PaymentDto, err error) {
+	p.report.read(PaymentsType, len(rows))
+
+	requests := requestsOf(rows)
+
+	if p.config.DryRun {
+		logDryRun(p.printRequests(), "payments", requests)
+
+		for _, request := range requests {
+			responses = append(responses, model.PaymentDto{
+				Id:          uuid.New(),
+				Name:        request.Name,
+				Description: request.Description,
+				Sum:         request.Sum,
+			})
+		}
+
+		return responses, nil
+	}
+
+	keys, err := contentKeys(rows)
 
-	if response, err := p.client.CreatePaymentBatch(request); err != nil {
-		log.Error().Err(err).Msg("error while creating payments")
+	if err != nil {
 		return nil, err
-	} else {
-		log.Info().Msg(fmt.Sprintf("%d payments created", len(response)))
-		return response, nil
 	}
+
+	// the restored payments are added in the order of the rows, so the report and the rollback are the same in every run
+	_, rows, err = resumeRows[model.CreatePaymentRequest, model.PaymentDto](p.journal, PaymentsType, rows, func(row Row[model.CreatePaymentRequest]) string { return keys[row.Line] }, func(row Row[model.CreatePaymentRequest], payment model.PaymentDto) {
+		responses = append(responses, payment)
+		p.report.entity(PaymentsType, payment.Id, row.Line, EntityRestored)
+	}, func(payments []model.PaymentDto) {
+		p.rollback(ctx, payments)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return responses, nil
+	}
+
+	created, err := createInChunks(ctx, PaymentsType, rows, p.batchSize, func(ctx context.Context, requests []model.CreatePaymentRequest) ([]model.PaymentDto, error) {
+		return p.client.CreatePaymentBatch(ctx, model.CreatePaymentBatchRequest{Payments: requests})
+	}, func(row Row[model.CreatePaymentRequest], payment model.PaymentDto) error {
+		p.report.entity(PaymentsType, payment.Id, row.Line, EntityCreated)
+
+		if err := p.journal.Created(PaymentsType, payment.Id, keys[row.Line], row.Line, payment); err != nil {
+			log.Error().Err(err).Msg("Failed to journal created payment")
+			return err
+		}
+		return nil
+	}, func(payment model.PaymentDto) error {
+		if err := p.journal.Created(PaymentsType, payment.Id, "", 0, payment); err != nil {
+			log.Error().Err(err).Msg("Failed to journal created payment")
+			return err
+		}
+		return nil
+	})
+
+	return append(responses, created...), err
+}
+
+// paymentRecord is a payment as it is declared in the source file.
+type paymentRecord struct {
+	HouseIdentifier string      `json:"houseIdentifier"`
+	Groups          []string    `json:"groups"`
+	Name            string      `json:"name"`
+	Description     string      `json:"description"`
+	Date            string      `json:"date"`
+	Sum             model.Money `json:"sum"`
+	Currency        string      `json:"currency"`
+	Provider        string      `json:"provider"`
 }
 
-func (p *PaymentMigrator) parseCSVLine() func(line []string, lineNumber int) (model.CreatePaymentRequest, error) {
+func (p *PaymentMigrator) parseCSVLine(amountFormat parser.AmountFormat) func(line []string, lineNumber int) (model.CreatePaymentRequest, error) {
 	return func(line []string, lineNumber int) (model.CreatePaymentRequest, error) {
-		sum, err := strconv.ParseFloat(line[4], 2)
+		sum, err := parser.ParseAmount(line[5], amountFormat)
 
 		if err != nil {
-			log.Error().Err(err).Msgf("sum not valid float %s at the csv line %d", line[3], lineNumber)
+			return model.CreatePaymentRequest{}, parser.NewRowError("Sum", line[5], err.Error())
+		}
+
+		return p.parseRecord(paymentRecord{
+			HouseIdentifier: line[0],
+			Groups:          splitList(line[1]),
+			Name:            line[2],
+			Description:     strings.Replace(line[3], ";", ",", -1),
+			Date:            line[4],
+			Sum:             sum,
+			Currency:        line[6],
+			Provider:        line[7],
+		}, lineNumber)
+	}
+}
+
+// parseOFXTransaction creates the payment of the house from the migrator file for a transaction of an OFX file.
+func (p *PaymentMigrator) parseOFXTransaction(house string) func(transaction parser.OFXTransaction, lineNumber int) (model.CreatePaymentRequest, error) {
+	return func(transaction parser.OFXTransaction, lineNumber int) (model.CreatePaymentRequest, error) {
+		if house == "" {
+			return model.CreatePaymentRequest{}, parser.NewRowError("House Identifier", house, "house of the OFX file is not set in the migrator file")
+		}
+
+		name, description := transaction.Name, transaction.Memo
+		if name == "" {
+			name, description = transaction.Memo, ""
+		}
+
+		return p.parseRecord(paymentRecord{
+			HouseIdentifier: house,
+			Name:            name,
+			Description:     description,
+			Date:            transaction.Date.Format(time.RFC3339),
+			Sum:             model.NewMoney(transaction.Amount.Neg()),
+		}, lineNumber)
+	}
+}
+
+func (p *PaymentMigrator) parseRecord(record paymentRecord, lineNumber int) (model.CreatePaymentRequest, error) {
+	var groupIds []string
+
+	for _, group := range record.Groups {
+		if dto, err := p.references.resolveGroup(p.groupMap, group); err != nil {
 			return model.CreatePaymentRequest{}, err
+		} else {
+			groupIds = append(groupIds, dto.Id.String())
 		}
+	}
 
-		houseId, err := func() (string, error) {
-			if dto, ok := p.houseMap[line[0]]; ok {
-				return dto.Id.String(), nil
-			}
-			return uuid.Nil.String(), fmt.Errorf("house identifier is missing at the csv line %d", lineNumber)
-		}()
+	var houseId *string
 
-		if err != nil {
+	if len(groupIds) == 0 {
+		if dto, err := p.references.resolveHouse(p.houseMap, record.HouseIdentifier); err != nil {
 			return model.CreatePaymentRequest{}, err
+		} else {
+			id := dto.Id.String()
+			houseId = &id
 		}
+	}
+
+	var providerId *string
 
-		request := model.CreatePaymentRequest{
-			Name:        line[1],
-			Description: strings.Replace(line[2], ";", ",", -1),
-			HouseId:     houseId,
-			UserId:      p.config.UserId,
-			Date:        line[3],
-			ProviderId:  nil,
-			Sum:         float32(sum),
+	if record.Provider != "" {
+		dto, ok := p.providerMap[record.Provider]
+		if !ok {
+			return model.CreatePaymentRequest{}, parser.NewRowError(providerColumn, record.Provider, "provider not found")
 		}
+		id := dto.Id.String()
+		providerId = &id
+	}
+
+	date, err := parser.ParseDate(record.Date, p.dateFormat)
+
+	if err != nil {
+		return model.CreatePaymentRequest{}, parser.NewRowError("Date", record.Date, err.Error())
+	}
+
+	sum, description, err := p.converter.convert(record.Sum, record.Currency, date, record.Description)
+
+	if err != nil {
+		return model.CreatePaymentRequest{}, err
+	}
 
-		return request, nil
+	request := model.CreatePaymentRequest{
+		Name:        record.Name,
+		Description: description,
+		HouseId:     houseId,
+		GroupIds:    groupIds,
+		UserId:      p.config.UserId,
+		Date:        date,
+		ProviderId:  providerId,
+		Sum:         sum,
 	}
+
+	return request, nil
 }
 
-func (p *PaymentMigrator) rollback(data []model.PaymentDto) {
+func (p *PaymentMigrator) rollback(ctx context.Context, data []model.PaymentDto) (result RollbackResult) {
 	log.Info().Msg("Rolling back payments")
 	if len(data) == 0 {
 		log.Info().Msg("No payments to rollback")
 	}
 
 	for _, payment := range data {
-		if err := p.client.DeletePaymentById(payment.Id); err != nil {
+		err := p.client.DeletePaymentById(ctx, payment.Id)
+
+		if err != nil {
 			log.Error().Err(err).Msgf("Failed to delete payment with id %s and name %s", payment.Id, payment.Name)
 		} else {
 			log.Info().Msgf("Payment with id %s and name %s deleted", payment.Id, payment.Name)
 		}
+
+		p.report.deleted(PaymentsType, payment.Id, err)
+		result.add(err)
+
+		if err := p.journal.Deleted(PaymentsType, payment.Id, err); err != nil {
+			log.Error().Err(err).Msgf("Failed to journal deleted payment with id %s", payment.Id)
+		}
 	}
+
+	return result
 }
 
-func (p *PaymentMigrator) Migrate(rollbackOperation []func()) ([]model.PaymentDto, []func()) {
+func (p *PaymentMigrator) Migrate(ctx context.Context, rollbackOperations []RollbackOperation) ([]model.PaymentDto, []RollbackOperation, error) {
 	if p != nil {
-		return p.BaseMigrator.Migrate(rollbackOperation)
+		return p.BaseMigrator.Migrate(ctx, rollbackOperations)
 	}
-	return nil, rollbackOperation
+	return nil, rollbackOperations, nil
 }
@@ -0,0 +1,267 @@
+package migrator
+
+import (
+	"context"
+	"github.com/VlasovArtem/hob-migration/src/client"
+	"github.com/VlasovArtem/hob-migration/src/config"
+	"github.com/VlasovArtem/hob-migration/src/journal"
+	"github.com/VlasovArtem/hob-migration/src/model"
+	"github.com/VlasovArtem/hob-migration/src/parser"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+type ProviderMigrator struct {
+	*BaseMigrator[map[string]model.ProviderDto]
+	config    *config.CMDConfig
+	client    *client.HobClient
+	journal   *journal.Journal
+	reused    map[uuid.UUID]bool
+	batchSize int
+}
+
+var providerHeader = []string{"Name", "Description", "Details"}
+
+func NewProviderMigrator(
+	requestMigrator RequestMigrator,
+	config *config.CMDConfig,
+	hobClient *client.HobClient,
+	journal *journal.Journal,
+) *ProviderMigrator {
+	log.Info().Msg("Starting Provider Migrator")
+
+	request, ok := requestMigrator.TypeToRequestMap[ProvidersType]
+	if !ok {
+		log.Info().Msg("providers path not found")
+		return nil
+	}
+	migrator := &ProviderMigrator{
+		client:    hobClient,
+		config:    config,
+		journal:   journal,
+		reused:    make(map[uuid.UUID]bool),
+		batchSize: request.batchSize(config),
+	}
+	filePath := request.Path
+	migrator.BaseMigrator = &BaseMigrator[map[string]model.ProviderDto]{
+		mappers: map[string]Mapper[map[string]model.ProviderDto]{
+			"csv": &CSVMigrator[model.CreateProviderRequest, map[string]model.ProviderDto]{
+				filePath: filePath,
+				format:   request.csvFormat(),
+				header:   providerHeader,
+				columns:  request.Columns,
+				parser:   migrator.parseCSVLine(),
+				mapper:   migrator.mapProviders,
+			},
+			"xlsx": &XLSXMigrator[model.CreateProviderRequest, map[string]model.ProviderDto]{
+				filePath:    filePath,
+				sheet:       "Providers",
+				header:      providerHeader,
+				columns:     request.Columns,
+				dateColumns: nil,
+				parser:      migrator.parseCSVLine(),
+				mapper:      migrator.mapProviders,
+			},
+			"json": &JSONMigrator[providerRecord, model.CreateProviderRequest, map[string]model.ProviderDto]{
+				filePath: filePath,
+				parser:   migrator.parseRecord,
+				mapper:   migrator.mapProviders,
+			},
+		},
+		filePath:   filePath,
+		format:     request.format(),
+		rollback:   migrator.rollback,
+		dryRun:     config.DryRun,
+		validation: requestMigrator.Validation,
+		entityType: ProvidersType,
+		report:     requestMigrator.Report,
+	}
+
+	return migrator
+}
+
+func (p *ProviderMigrator) mapProviders(ctx context.Context, rows []Row[model.CreateProviderRequest]) (map[string]model.ProviderDto, error) {
+	p.report.read(ProvidersType, len(rows))
+
+	response, rows, err := resumeRows[model.CreateProviderRequest, model.ProviderDto](p.journal, ProvidersType, rows, providerKey, func(row Row[model.CreateProviderRequest], provider model.ProviderDto) {
+		p.report.entity(ProvidersType, provider.Id, row.Line, EntityRestored)
+	}, func(providers []model.ProviderDto) {
+		p.rollback(ctx, byId(providers, func(provider model.ProviderDto) uuid.UUID { return provider.Id }))
+	})
+
+	if err != nil {
+		return response, err
+	}
+
+	reused, rows, err := p.reuseExisting(ctx, rows)
+
+	if err != nil {
+		return response, err
+	}
+
+	for name, provider := range reused {
+		response[name] = provider
+	}
+
+	if len(rows) == 0 {
+		return response, nil
+	}
+
+	if p.config.DryRun {
+		requests := requestsOf(rows)
+
+		logDryRun(p.printRequests(), "providers", requests)
+
+		userId, _ := uuid.Parse(p.config.UserId)
+
+		for _, request := range requests {
+			response[request.Name] = model.ProviderDto{
+				Id:          uuid.New(),
+				Name:        request.Name,
+				Description: request.Description,
+				Details:     request.Details,
+				UserId:      userId,
+			}
+		}
+
+		return response, nil
+	}
+
+	_, err = createInChunks(ctx, ProvidersType, rows, p.batchSize, func(ctx context.Context, requests []model.CreateProviderRequest) ([]model.ProviderDto, error) {
+		return p.client.CreateProviderBatch(ctx, model.CreateProviderBatchRequest{Providers: requests})
+	}, func(row Row[model.CreateProviderRequest], provider model.ProviderDto) error {
+		response[row.Request.Name] = provider
+		p.report.entity(ProvidersType, provider.Id, row.Line, EntityCreated)
+
+		if err := p.journal.Created(ProvidersType, provider.Id, row.Request.Name, row.Line, provider); err != nil {
+			log.Error().Err(err).Msg("Failed to journal created provider")
+			return err
+		}
+		return nil
+	}, func(provider model.ProviderDto) error {
+		response[provider.Id.String()] = provider
+
+		if err := p.journal.Created(ProvidersType, provider.Id, "", 0, provider); err != nil {
+			log.Error().Err(err).Msg("Failed to journal created provider")
+			return err
+		}
+		return nil
+	})
+
+	return response, err
+}
+
+// reuseExisting matches the rows with the providers of the user that already exist in HOB by name.
+func (p *ProviderMigrator) reuseExisting(ctx context.Context, rows []Row[model.CreateProviderRequest]) (map[string]model.ProviderDto, []Row[model.CreateProviderRequest], error) {
+	reused := make(map[string]model.ProviderDto)
+
+	if !p.config.ReuseExisting || len(rows) == 0 {
+		return reused, rows, nil
+	}
+
+	providers, err := p.client.GetProvidersByUserId(ctx, p.config.UserId)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get existing providers")
+		return nil, nil, err
+	}
+
+	existing := make(map[string]model.ProviderDto)
+
+	for _, provider := range providers {
+		if _, ok := existing[provider.Name]; !ok {
+			existing[provider.Name] = provider
+		}
+	}
+
+	var pending []Row[model.CreateProviderRequest]
+
+	for _, row := range rows {
+		if provider, ok := existing[row.Request.Name]; ok {
+			reused[row.Request.Name] = provider
+			p.reused[provider.Id] = true
+			p.report.entity(ProvidersType, provider.Id, row.Line, EntityReused)
+		} else {
+			pending = append(pending, row)
+		}
+	}
+
+	log.Info().Msgf("%d providers already exist and will be reused, %d providers to create", len(reused), len(pending))
+
+	return reused, pending, nil
+}
+
+func providerKey(row Row[model.CreateProviderRequest]) string {
+	return row.Request.Name
+}
+
+// providerRecord is a provider as it is declared in the source file.
+type providerRecord struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Details     string `json:"details"`
+}
+
+func (p *ProviderMigrator) parseCSVLine() func(line []string, lineNumber int) (model.CreateProviderRequest, error) {
+	return func(line []string, lineNumber int) (model.CreateProviderRequest, error) {
+		return p.parseRecord(providerRecord{
+			Name:        line[0],
+			Description: strings.Replace(line[1], ";", ",", -1),
+			Details:     strings.Replace(line[2], ";", ",", -1),
+		}, lineNumber)
+	}
+}
+
+func (p *ProviderMigrator) parseRecord(record providerRecord, lineNumber int) (model.CreateProviderRequest, error) {
+	if strings.TrimSpace(record.Name) == "" {
+		return model.CreateProviderRequest{}, parser.NewRowError("Name", record.Name, "name is empty")
+	}
+
+	request := model.CreateProviderRequest{
+		Name:        record.Name,
+		Description: record.Description,
+		Details:     record.Details,
+		UserId:      p.config.UserId,
+	}
+
+	return request, nil
+}
+
+func (p *ProviderMigrator) rollback(ctx context.Context, data map[string]model.ProviderDto) (result RollbackResult) {
+	log.Info().Msg("Rolling back providers")
+	if len(data) == 0 {
+		log.Info().Msg("No providers to rollback")
+	}
+
+	for _, provider := range data {
+		if p.reused[provider.Id] {
+			log.Info().Msgf("Provider with id %s and name %s existed before the migration, skipped", provider.Id, provider.Name)
+			continue
+		}
+
+		err := p.client.DeleteProviderById(ctx, provider.Id)
+
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to delete provider with id %s and name %s", provider.Id, provider.Name)
+		} else {
+			log.Info().Msgf("Provider with id %s and name %s deleted", provider.Id, provider.Name)
+		}
+
+		p.report.deleted(ProvidersType, provider.Id, err)
+		result.add(err)
+
+		if err := p.journal.Deleted(ProvidersType, provider.Id, err); err != nil {
+			log.Error().Err(err).Msgf("Failed to journal deleted provider with id %s", provider.Id)
+		}
+	}
+
+	return result
+}
+
+func (p *ProviderMigrator) Migrate(ctx context.Context, rollbackOperations []RollbackOperation) (map[string]model.ProviderDto, []RollbackOperation, error) {
+	if p != nil {
+		return p.BaseMigrator.Migrate(ctx, rollbackOperations)
+	}
+	return nil, rollbackOperations, nil
+}
@@ -0,0 +1,116 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/client"
+	"github.com/VlasovArtem/hob-migration/src/model"
+	"github.com/VlasovArtem/hob-migration/src/parser"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// References are the houses and groups of the user that exist in HOB before the migration. The rows of the files
+// can reference them by id or by exact name instead of the House Identifier or the group name of a migrated file.
+type References struct {
+	houses []model.HouseDto
+	groups []model.GroupDto
+}
+
+// LoadReferences reads the houses and groups of the user from HOB.
+func LoadReferences(ctx context.Context, hobClient *client.HobClient, userId string) (*References, error) {
+	houses, err := hobClient.GetHousesByUserId(ctx, userId)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get existing houses")
+	}
+
+	groups, err := hobClient.GetGroupsByUserId(ctx, userId)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get existing groups")
+	}
+
+	log.Info().Msgf("%d existing houses and %d existing groups can be referenced by id or name", len(houses), len(groups))
+
+	return &References{houses: houses, groups: groups}, nil
+}
+
+// resolveHouse returns the house of the House Identifier of the migrated houses, or the existing house with the id
+// or the name.
+func (r *References) resolveHouse(houseMap map[string]model.HouseDto, identifier string) (model.HouseDto, error) {
+	if dto, ok := houseMap[identifier]; ok {
+		return dto, nil
+	}
+
+	var houses []model.HouseDto
+
+	if r != nil {
+		houses = r.houses
+	}
+
+	dto, err := resolveExisting(houses, identifier, "house", func(house model.HouseDto) (uuid.UUID, string) {
+		return house.Id, house.Name
+	})
+
+	if err != nil {
+		return model.HouseDto{}, parser.NewRowError("House Identifier", identifier, err.Error())
+	}
+
+	return dto, nil
+}
+
+// resolveGroup returns the group of the name of the migrated groups, or the existing group with the id or the name.
+func (r *References) resolveGroup(groupMap map[string]model.GroupDto, name string) (model.GroupDto, error) {
+	if dto, ok := groupMap[name]; ok {
+		return dto, nil
+	}
+
+	var groups []model.GroupDto
+
+	if r != nil {
+		groups = r.groups
+	}
+
+	dto, err := resolveExisting(groups, name, "group", func(group model.GroupDto) (uuid.UUID, string) {
+		return group.Id, group.Name
+	})
+
+	if err != nil {
+		return model.GroupDto{}, parser.NewRowError(groupsColumn, name, err.Error())
+	}
+
+	return dto, nil
+}
+
+// resolveExisting finds the entity by id, or by name if the name is not an id. A name of several entities is an
+// error, such entities can be referenced by id only.
+func resolveExisting[DTO any](entities []DTO, reference string, entityType string, keyOf func(DTO) (uuid.UUID, string)) (DTO, error) {
+	if id, err := uuid.Parse(reference); err == nil {
+		for _, entity := range entities {
+			if entityId, _ := keyOf(entity); entityId == id {
+				return entity, nil
+			}
+		}
+
+		return *new(DTO), fmt.Errorf("%s not found", entityType)
+	}
+
+	var found []DTO
+
+	for _, entity := range entities {
+		if _, name := keyOf(entity); name == reference {
+			found = append(found, entity)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return *new(DTO), fmt.Errorf("%s not found", entityType)
+	case 1:
+		return found[0], nil
+	}
+
+	return *new(DTO), fmt.Errorf("%d existing %ss are named %s, reference the %s by id", len(found), entityType, reference, entityType)
+}
@@ -0,0 +1,255 @@
+package migrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ReportCompleted  = "completed"
+	ReportRolledBack = "rolled-back"
+	ReportInvalid    = "invalid"
+	// ReportInterrupted is the status of an interrupted migration with the created entities kept
+	ReportInterrupted = "interrupted"
+	// ReportFailed is the status of a failed migration with the created entities kept
+	ReportFailed = "failed"
+)
+
+const (
+	EntityCreated  = "created"
+	EntityReused   = "reused"
+	EntityRestored = "restored"
+)
+
+// Report is the result of the migration per entity type with the line of the source file of every entity, so every
+// HOB record can be traced back to its row.
+type Report struct {
+	RunId      string         `json:"runId"`
+	DryRun     bool           `json:"dryRun"`
+	Status     string         `json:"status"`
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt time.Time      `json:"finishedAt"`
+	DurationMs int64          `json:"durationMs"`
+	Stages     []*StageReport `json:"stages"`
+
+	path         string
+	markdownPath string
+	mutex        sync.Mutex
+}
+
+type StageReport struct {
+	Type         string           `json:"type"`
+	File         string           `json:"file"`
+	RowsRead     int              `json:"rowsRead"`
+	RowsCreated  int              `json:"rowsCreated"`
+	RowsReused   int              `json:"rowsReused"`
+	RowsRestored int              `json:"rowsRestored"`
+	DurationMs   int64            `json:"durationMs"`
+	Error        string           `json:"error,omitempty"`
+	Entities     []EntityReport   `json:"entities"`
+	Rollback     []RollbackReport `json:"rollback,omitempty"`
+}
+
+type EntityReport struct {
+	Id     uuid.UUID `json:"id"`
+	Line   int       `json:"line"`
+	Action string    `json:"action"`
+}
+
+type RollbackReport struct {
+	Id      uuid.UUID `json:"id"`
+	Deleted bool      `json:"deleted"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// NewReport creates the report that is written to the JSON file of the path, and to the Markdown file of the
+// markdown path if it is set.
+func NewReport(path string, markdownPath string, runId string, dryRun bool) *Report {
+	return &Report{
+		RunId:        runId,
+		DryRun:       dryRun,
+		StartedAt:    time.Now().UTC(),
+		Stages:       []*StageReport{},
+		path:         path,
+		markdownPath: markdownPath,
+	}
+}
+
+func (r *Report) Path() string {
+	if r == nil {
+		return ""
+	}
+	return r.path
+}
+
+func (r *Report) stage(entityType string) *StageReport {
+	for _, stage := range r.Stages {
+		if stage.Type == entityType {
+			return stage
+		}
+	}
+
+	stage := &StageReport{Type: entityType, Entities: []EntityReport{}}
+	r.Stages = append(r.Stages, stage)
+
+	return stage
+}
+
+func (r *Report) read(entityType string, rows int) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.stage(entityType).RowsRead = rows
+}
+
+// entity adds the entity of the source line, the dry run has no entities, the ids are placeholders.
+func (r *Report) entity(entityType string, id uuid.UUID, line int, action string) {
+	if r == nil || r.DryRun {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stage := r.stage(entityType)
+	stage.Entities = append(stage.Entities, EntityReport{Id: id, Line: line, Action: action})
+
+	switch action {
+	case EntityCreated:
+		stage.RowsCreated++
+	case EntityReused:
+		stage.RowsReused++
+	case EntityRestored:
+		stage.RowsRestored++
+	}
+}
+
+func (r *Report) deleted(entityType string, id uuid.UUID, err error) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	rollback := RollbackReport{Id: id, Deleted: err == nil}
+	if err != nil {
+		rollback.Error = err.Error()
+	}
+
+	stage := r.stage(entityType)
+	stage.Rollback = append(stage.Rollback, rollback)
+}
+
+func (r *Report) finish(entityType string, filePath string, duration time.Duration, err error) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stage := r.stage(entityType)
+	stage.File = filePath
+	stage.DurationMs = duration.Milliseconds()
+
+	if err != nil {
+		stage.Error = err.Error()
+	}
+}
+
+// Write writes the report with the status of the migration.
+func (r *Report) Write(status string) error {
+	if r == nil {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Status = status
+	r.FinishedAt = time.Now().UTC()
+	r.DurationMs = r.FinishedAt.Sub(r.StartedAt).Milliseconds()
+
+	reportBytes, err := json.MarshalIndent(r, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(r.path, append(reportBytes, '\n'), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write report %s", r.path)
+	}
+
+	if r.markdownPath == "" {
+		return nil
+	}
+
+	if err := os.WriteFile(r.markdownPath, []byte(r.markdown()), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write report %s", r.markdownPath)
+	}
+
+	return nil
+}
+
+func (r *Report) markdown() string {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "# Migration %s\n\n", r.RunId)
+	fmt.Fprintf(&builder, "Status: %s, dry run: %t, started at %s, duration %s\n\n", r.Status, r.DryRun, r.StartedAt.Format(time.RFC3339), time.Duration(r.DurationMs)*time.Millisecond)
+
+	builder.WriteString("| Type | File | Rows read | Created | Reused | Restored | Duration | Error |\n")
+	builder.WriteString("|------|------|-----------|---------|--------|----------|----------|-------|\n")
+
+	for _, stage := range r.Stages {
+		fmt.Fprintf(&builder, "| %s | %s | %d | %d | %d | %d | %s | %s |\n",
+			stage.Type, markdownCell(stage.File), stage.RowsRead, stage.RowsCreated, stage.RowsReused, stage.RowsRestored,
+			time.Duration(stage.DurationMs)*time.Millisecond, markdownCell(stage.Error))
+	}
+
+	for _, stage := range r.Stages {
+		if len(stage.Entities) == 0 && len(stage.Rollback) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&builder, "\n## %s\n\n", stage.Type)
+
+		if len(stage.Entities) != 0 {
+			builder.WriteString("| Line | Id | Action |\n")
+			builder.WriteString("|------|----|--------|\n")
+
+			for _, entity := range stage.Entities {
+				fmt.Fprintf(&builder, "| %d | %s | %s |\n", entity.Line, entity.Id, entity.Action)
+			}
+		}
+
+		if len(stage.Rollback) != 0 {
+			builder.WriteString("\nRollback:\n\n")
+			builder.WriteString("| Id | Deleted | Error |\n")
+			builder.WriteString("|----|---------|-------|\n")
+
+			for _, rollback := range stage.Rollback {
+				fmt.Fprintf(&builder, "| %s | %t | %s |\n", rollback.Id, rollback.Deleted, markdownCell(rollback.Error))
+			}
+		}
+	}
+
+	return builder.String()
+}
+
+// markdownCellReplacer escapes the pipes and replaces the line breaks, which would end the cell of a table.
+var markdownCellReplacer = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+func markdownCell(value string) string {
+	return markdownCellReplacer.Replace(value)
+}
@@ -0,0 +1,51 @@
+package migrator
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportCountsEntityActions(t *testing.T) {
+	report := NewReport("", "", "run", false)
+	report.read(IncomesType, 3)
+	report.entity(IncomesType, uuid.New(), 2, EntityCreated)
+	report.entity(IncomesType, uuid.New(), 3, EntityReused)
+	report.entity(IncomesType, uuid.New(), 4, EntityRestored)
+
+	stage := report.Stages[0]
+
+	if stage.RowsRead != 3 || stage.RowsCreated != 1 || stage.RowsReused != 1 || stage.RowsRestored != 1 || len(stage.Entities) != 3 {
+		t.Errorf("expected 1 created, reused and restored of 3 rows, got %+v", stage)
+	}
+}
+
+func TestDryRunReportHasNoEntities(t *testing.T) {
+	report := NewReport("", "", "run", true)
+	report.entity(IncomesType, uuid.New(), 2, EntityCreated)
+
+	if stage := report.stage(IncomesType); len(stage.Entities) != 0 || stage.RowsCreated != 0 {
+		t.Errorf("expected no entities of the dry run, got %+v", stage)
+	}
+}
+
+func TestMarkdownCellsAreEscaped(t *testing.T) {
+	id := uuid.New()
+
+	report := NewReport("", "", "run", false)
+	report.finish(PaymentsType, "payments|2022.csv", time.Second, errors.New("invalid row\nsum | date"))
+	report.deleted(PaymentsType, id, errors.New("hob responded with status 500:\r\nfailed"))
+
+	markdown := report.markdown()
+
+	for _, expected := range []string{
+		`| payments | payments\|2022.csv | 0 | 0 | 0 | 0 | 1s | invalid row<br>sum \| date |`,
+		"| " + id.String() + " | false | hob responded with status 500:<br>failed |",
+	} {
+		if !strings.Contains(markdown, expected+"\n") {
+			t.Errorf("expected the row %s, got\n%s", expected, markdown)
+		}
+	}
+}
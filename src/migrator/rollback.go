@@ -0,0 +1,72 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/client"
+	"github.com/VlasovArtem/hob-migration/src/journal"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// RollbackJournal deletes every entity from the journal that was not deleted yet, in the reverse order of
+// creation. The result of every delete is appended to the same journal, so the rollback can be repeated.
+func RollbackJournal(ctx context.Context, path string, hobClient *client.HobClient) error {
+	entries, err := journal.Read(path)
+
+	if err != nil {
+		return err
+	}
+
+	migrationJournal, err := journal.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer migrationJournal.Close()
+
+	deleteByType := map[string]func(ctx context.Context, id uuid.UUID) error{
+		GroupsType:    hobClient.DeleteGroupById,
+		HousesType:    hobClient.DeleteHouseById,
+		ProvidersType: hobClient.DeleteProviderById,
+		IncomesType:   hobClient.DeleteIncomeById,
+		PaymentsType:  hobClient.DeletePaymentById,
+	}
+
+	pending := journal.Pending(entries)
+
+	log.Info().Msgf("%d entities to rollback from journal %s", len(pending), path)
+
+	var failed int
+
+	for i := len(pending) - 1; i >= 0; i-- {
+		entry := pending[i]
+
+		deleteById, ok := deleteByType[entry.Type]
+		if !ok {
+			log.Error().Msgf("Unknown entity type %s with id %s", entry.Type, entry.Id)
+			failed++
+			continue
+		}
+
+		err := deleteById(ctx, entry.Id)
+
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to delete %s with id %s", entry.Type, entry.Id)
+			failed++
+		} else {
+			log.Info().Msgf("%s with id %s deleted", entry.Type, entry.Id)
+		}
+
+		if err := migrationJournal.Deleted(entry.Type, entry.Id, err); err != nil {
+			return err
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d of %d entities were not deleted", failed, len(pending))
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package migrator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/parser"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Validation collects the errors of all files of the migration instead of stopping at the first one. The files are
+// validated with a dry run before the migration, so the errors are reported before any data is sent to HOB.
+type Validation struct {
+	// Quiet does not print the requests of the dry run
+	Quiet  bool
+	Errors []*parser.RowError
+
+	collected map[parser.RowError]bool
+}
+
+// add collects the errors of the file. The errors of a file that is read by several migrators, like the invalid
+// transactions of an OFX file with both incomes and payments, are collected once.
+func (v *Validation) add(filePath string, err error) {
+	var rowErrors parser.RowErrors
+
+	if !errors.As(err, &rowErrors) {
+		rowErrors = parser.RowErrors{parser.ToRowError(err, filePath, 0)}
+	}
+
+	if v.collected == nil {
+		v.collected = make(map[parser.RowError]bool)
+	}
+
+	for _, rowError := range rowErrors {
+		key := parser.RowError{File: filepath.Clean(rowError.File), Line: rowError.Line, Column: rowError.Column, Reason: rowError.Reason}
+
+		if !v.collected[key] {
+			v.collected[key] = true
+			v.Errors = append(v.Errors, rowError)
+		}
+	}
+}
+
+// Print writes the errors as a table.
+func (v *Validation) Print(writer io.Writer) error {
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(table, "FILE\tLINE\tCOLUMN\tVALUE\tREASON")
+
+	for _, rowError := range v.Errors {
+		fmt.Fprintf(table, "%s\t%d\t%s\t%s\t%s\n", rowError.File, rowError.Line, rowError.Column, rowError.Value, rowError.Reason)
+	}
+
+	return table.Flush()
+}
+
+// WriteFile writes the errors to a JSON file if the path has the json extension, otherwise to a CSV file.
+func (v *Validation) WriteFile(path string) error {
+	file, err := os.Create(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(v.Errors); err != nil {
+			return err
+		}
+
+		return file.Close()
+	}
+
+	writer := csv.NewWriter(file)
+
+	if err := writer.Write([]string{"File", "Line", "Column", "Value", "Reason"}); err != nil {
+		return err
+	}
+
+	for _, rowError := range v.Errors {
+		line := []string{rowError.File, strconv.Itoa(rowError.Line), rowError.Column, rowError.Value, rowError.Reason}
+
+		if err := writer.Write(line); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
@@ -27,6 +27,7 @@ type HouseDto struct {
 	StreetLine1 string
 	StreetLine2 string
 	UserId      uuid.UUID
+	Groups      []GroupDto
 }
 
 type GroupDto struct {
@@ -48,7 +49,7 @@ type CreateIncomeRequest struct {
 	Name        string
 	Description string
 	Date        string
-	Sum         float32
+	Sum         Money
 	HouseId     *string
 	GroupIds    []string
 }
@@ -62,18 +63,39 @@ type IncomeDto struct {
 	Name        string
 	Description string
 	Date        time.Time
-	Sum         float32
+	Sum         Money
 	HouseId     uuid.UUID
+	Groups      []GroupDto
+}
+
+type CreateProviderRequest struct {
+	Name        string
+	Description string
+	Details     string
+	UserId      string
+}
+
+type CreateProviderBatchRequest struct {
+	Providers []CreateProviderRequest
+}
+
+type ProviderDto struct {
+	Id          uuid.UUID
+	Name        string
+	Description string
+	Details     string
+	UserId      uuid.UUID
 }
 
 type CreatePaymentRequest struct {
 	Name        string
 	Description string
-	HouseId     string
+	HouseId     *string
+	GroupIds    []string
 	UserId      string
 	ProviderId  *string
 	Date        string
-	Sum         float32
+	Sum         Money
 }
 
 type CreatePaymentBatchRequest struct {
@@ -85,8 +107,9 @@ type PaymentDto struct {
 	Name        string
 	Description string
 	HouseId     uuid.UUID
+	Groups      []GroupDto
 	UserId      uuid.UUID
 	ProviderId  uuid.UUID
 	Date        time.Time
-	Sum         float32
+	Sum         Money
 }
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// Money is an exact decimal amount. It is sent to HOB as a JSON number without the rounding of a float.
+type Money struct {
+	decimal.Decimal
+}
+
+func NewMoney(value decimal.Decimal) Money {
+	return Money{Decimal: value}
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal.String()), nil
+}
@@ -0,0 +1,236 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/model"
+	"github.com/shopspring/decimal"
+	"golang.org/x/exp/slices"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// AmountFormat describes the separators of the amounts of a file. Without a decimal separator it is detected for
+// every value: when both '.' and ',' are used the last one is the decimal separator, a single separator followed by
+// exactly three digits is a thousands separator, otherwise it is the decimal separator. The configured thousands
+// separator is never detected as the decimal separator.
+type AmountFormat struct {
+	DecimalSeparator   string
+	ThousandsSeparator string
+	// RawNumbers reads plain numbers, like the raw values of XLSX numeric cells, as is before the separators
+	// are applied
+	RawNumbers bool
+}
+
+var (
+	rawNumberPattern = regexp.MustCompile(`^-?\d+(\.\d+)?([eE][+-]?\d+)?$`)
+	// spaces and apostrophes are always thousands separators
+	groupSeparators = []string{" ", "\u00a0", "\u202f", "'", "\u2019"}
+	minusSigns      = []string{"-", "\u2212"}
+)
+
+// ParseAmount parses an amount with optional currency symbols or codes around the number. A negative amount has
+// a minus sign before or after the number, or is enclosed in parentheses.
+func ParseAmount(value string, format AmountFormat) (model.Money, error) {
+	amount := strings.TrimSpace(value)
+
+	if format.RawNumbers && rawNumberPattern.MatchString(amount) {
+		if number, err := decimal.NewFromString(amount); err == nil {
+			return model.NewMoney(number), nil
+		}
+	}
+
+	signs := 0
+
+	if strings.HasPrefix(amount, "(") && strings.HasSuffix(amount, ")") {
+		signs++
+		amount = amount[1 : len(amount)-1]
+	}
+
+	amount = strings.TrimFunc(amount, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '-' && r != '\u2212' && r != '.' && r != ','
+	})
+
+	for _, minus := range minusSigns {
+		if strings.HasPrefix(amount, minus) {
+			signs++
+			amount = strings.TrimPrefix(amount, minus)
+		}
+		if strings.HasSuffix(amount, minus) {
+			signs++
+			amount = strings.TrimSuffix(amount, minus)
+		}
+	}
+
+	if signs > 1 || strings.ContainsAny(amount, strings.Join(minusSigns, "")) {
+		return model.Money{}, fmt.Errorf("amount %s has more than one sign", value)
+	}
+
+	negative := signs == 1
+
+	amount = strings.TrimFunc(amount, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.' && r != ','
+	})
+
+	if amount == "" {
+		return model.Money{}, fmt.Errorf("amount %s is not a number", value)
+	}
+
+	normalized, err := normalizeAmount(amount, format)
+
+	if err != nil {
+		return model.Money{}, fmt.Errorf("amount %s is not valid: %s", value, err.Error())
+	}
+
+	number, err := decimal.NewFromString(normalized)
+
+	if err != nil {
+		return model.Money{}, fmt.Errorf("amount %s is not a number", value)
+	}
+
+	if negative {
+		number = number.Neg()
+	}
+
+	return model.NewMoney(number), nil
+}
+
+// normalizeAmount removes the thousands separators and replaces the decimal separator with '.'. The thousands
+// separators are followed by exactly three digits, the decimal separator is used once after the last group.
+func normalizeAmount(amount string, format AmountFormat) (string, error) {
+	decimalSeparator := format.DecimalSeparator
+
+	if decimalSeparator == "" {
+		decimalSeparator = detectDecimalSeparator(amount, format.ThousandsSeparator)
+	}
+
+	integerPart, fraction := amount, ""
+
+	if index := strings.Index(amount, decimalSeparator); decimalSeparator != "" && index != -1 {
+		if strings.Count(amount, decimalSeparator) > 1 {
+			return "", fmt.Errorf("decimal separator %s is used more than once", decimalSeparator)
+		}
+
+		integerPart, fraction = amount[:index], amount[index+len(decimalSeparator):]
+
+		if fraction == "" || strings.IndexFunc(fraction, func(r rune) bool { return !unicode.IsDigit(r) }) != -1 {
+			return "", fmt.Errorf("decimal separator %s is not followed by the digits of the fraction", decimalSeparator)
+		}
+
+		if integerPart == "" {
+			integerPart = "0"
+		}
+	}
+
+	digits, err := removeThousandsSeparators(integerPart, thousandsSeparators(format, decimalSeparator))
+
+	if err != nil {
+		return "", err
+	}
+
+	if fraction != "" {
+		return digits + "." + fraction, nil
+	}
+
+	return digits, nil
+}
+
+// thousandsSeparators are the separators of the groups of the integer part, the spaces and apostrophes and either
+// the configured thousands separator or '.' and ','.
+func thousandsSeparators(format AmountFormat, decimalSeparator string) []string {
+	separators := []string{".", ","}
+	if format.ThousandsSeparator != "" {
+		separators = []string{format.ThousandsSeparator}
+	}
+
+	var allowed []string
+
+	for _, separator := range append(separators, groupSeparators...) {
+		if separator != decimalSeparator {
+			allowed = append(allowed, separator)
+		}
+	}
+
+	return allowed
+}
+
+// removeThousandsSeparators returns the digits of the integer part. The first group has up to three digits, every
+// next group exactly three, all groups are divided by the same separator.
+func removeThousandsSeparators(integerPart string, separators []string) (string, error) {
+	var groups []string
+	var separator string
+	var group strings.Builder
+
+	for _, r := range integerPart {
+		switch {
+		case unicode.IsDigit(r):
+			group.WriteRune(r)
+		case slices.Contains(separators, string(r)):
+			if separator != "" && separator != string(r) {
+				return "", fmt.Errorf("thousands separators %s and %c are mixed", separator, r)
+			}
+			separator = string(r)
+			groups = append(groups, group.String())
+			group.Reset()
+		default:
+			return "", fmt.Errorf("unexpected character %c", r)
+		}
+	}
+
+	groups = append(groups, group.String())
+
+	if len(groups) > 1 {
+		if len(groups[0]) == 0 || len(groups[0]) > 3 {
+			return "", fmt.Errorf("thousands separator %s is not preceded by one to three digits", separator)
+		}
+
+		for _, group := range groups[1:] {
+			if len(group) != 3 {
+				return "", fmt.Errorf("thousands separator %s is not followed by three digits", separator)
+			}
+		}
+	}
+
+	return strings.Join(groups, ""), nil
+}
+
+// detectDecimalSeparator returns the decimal separator of the amount, empty if the amount has no fraction. The
+// configured thousands separator is never the decimal separator.
+func detectDecimalSeparator(amount string, thousandsSeparator string) string {
+	separator, index := "", -1
+
+	for _, candidate := range []string{".", ","} {
+		if candidate == thousandsSeparator {
+			continue
+		}
+		if last := strings.LastIndex(amount, candidate); last > index {
+			separator, index = candidate, last
+		}
+	}
+
+	// a separator used several times divides the groups
+	if separator == "" || strings.Count(amount, separator) > 1 {
+		return ""
+	}
+
+	// the other separator is the thousands separator
+	if thousandsSeparator != "" || strings.Contains(amount, otherSeparator(separator)) {
+		return separator
+	}
+
+	integerPart, fraction := amount[:index], amount[index+1:]
+
+	// a single separator followed by exactly three digits divides the groups, 1,000 is 1000
+	if len(fraction) == 3 && integerPart != "" && strings.Trim(integerPart, "0") != "" {
+		return ""
+	}
+
+	return separator
+}
+
+func otherSeparator(separator string) string {
+	if separator == "." {
+		return ","
+	}
+	return "."
+}
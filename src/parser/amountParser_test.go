@@ -0,0 +1,79 @@
+package parser
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value    string
+		format   AmountFormat
+		expected string
+	}{
+		{value: "100", expected: "100"},
+		{value: "100.01", expected: "100.01"},
+		{value: "100,01", expected: "100.01"},
+		{value: "1,000", expected: "1000"},
+		{value: "0,500", expected: "0.5"},
+		{value: "1,234.56", expected: "1234.56"},
+		{value: "1.234,56", expected: "1234.56"},
+		{value: "1 234,50", expected: "1234.5"},
+		{value: "1,234,567.89", expected: "1234567.89"},
+		{value: "1.234.567", expected: "1234567"},
+		{value: ".5", expected: "0.5"},
+		{value: "₴1 234,56", expected: "1234.56"},
+		{value: "100.01 UAH", expected: "100.01"},
+		{value: "-5", expected: "-5"},
+		{value: "5-", expected: "-5"},
+		{value: "−5", expected: "-5"},
+		{value: "(100.01)", expected: "-100.01"},
+		{value: "100,50", format: AmountFormat{DecimalSeparator: ","}, expected: "100.5"},
+		{value: "1.234,5", format: AmountFormat{DecimalSeparator: ","}, expected: "1234.5"},
+		{value: "1.234", format: AmountFormat{DecimalSeparator: "."}, expected: "1.234"},
+		{value: "1,234", format: AmountFormat{ThousandsSeparator: "."}, expected: "1.234"},
+		{value: "1,234", format: AmountFormat{ThousandsSeparator: ","}, expected: "1234"},
+		{value: "1.5e3", format: AmountFormat{RawNumbers: true}, expected: "1500"},
+	}
+
+	for _, test := range tests {
+		amount, err := ParseAmount(test.value, test.format)
+
+		if err != nil {
+			t.Errorf("%q %+v: unexpected error %v", test.value, test.format, err)
+			continue
+		}
+
+		if amount.String() != test.expected {
+			t.Errorf("%q %+v: expected %s, got %s", test.value, test.format, test.expected, amount)
+		}
+	}
+}
+
+func TestParseAmountRejectsMalformed(t *testing.T) {
+	tests := []struct {
+		value  string
+		format AmountFormat
+	}{
+		{value: ""},
+		{value: "UAH"},
+		{value: "1.2.3"},
+		{value: "1,2,3"},
+		{value: "12.5."},
+		{value: "100."},
+		{value: "--5"},
+		{value: "-5-"},
+		{value: "(-5)"},
+		{value: "1234,567"},
+		{value: "1,23.45"},
+		{value: "1.234,567,8"},
+		{value: "1.234 567"},
+		{value: "2022-01-05"},
+		{value: "100.50", format: AmountFormat{DecimalSeparator: ","}},
+		{value: "1,23", format: AmountFormat{ThousandsSeparator: ","}},
+		{value: "1,5,0", format: AmountFormat{DecimalSeparator: ","}},
+	}
+
+	for _, test := range tests {
+		if amount, err := ParseAmount(test.value, test.format); err == nil {
+			t.Errorf("%q %+v: expected an error, got %s", test.value, test.format, amount)
+		}
+	}
+}
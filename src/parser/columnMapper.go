@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"fmt"
+	"golang.org/x/exp/slices"
+	"strings"
+)
+
+// ColumnMapping declares where the value of a column of the migrator header comes from. The source column is found
+// by the header name (Column) or by the zero based index (Index), without both by the name of the migrator column.
+// Value is a constant used instead of the source column, Default is used when the source value is empty or the
+// source column does not exist.
+type ColumnMapping struct {
+	Column  string  `json:"column"`
+	Index   *int    `json:"index"`
+	Value   *string `json:"value"`
+	Default *string `json:"default"`
+}
+
+// ColumnMapper rearranges the lines of a source file to the order of the migrator header.
+type ColumnMapper struct {
+	sources []columnSource
+}
+
+type columnSource struct {
+	index        int
+	value        *string
+	defaultValue *string
+}
+
+func NewColumnMapper(header []string, sourceHeader []string, columns map[string]ColumnMapping) (*ColumnMapper, error) {
+	for column := range columns {
+		if !slices.Contains(header, column) {
+			return nil, fmt.Errorf("mapped column %s is not a column of the migrator. Columns: %s", column, strings.Join(header, ","))
+		}
+	}
+
+	mapper := &ColumnMapper{}
+
+	for _, column := range header {
+		mapping := columns[column]
+		source := columnSource{index: -1, value: mapping.Value, defaultValue: mapping.Default}
+
+		if mapping.Value == nil {
+			switch {
+			case mapping.Index != nil:
+				if *mapping.Index < 0 || *mapping.Index >= len(sourceHeader) {
+					return nil, fmt.Errorf("index %d of the column %s is out of range of the header %s", *mapping.Index, column, strings.Join(sourceHeader, ","))
+				}
+				source.index = *mapping.Index
+			case mapping.Column != "":
+				source.index = slices.Index(sourceHeader, mapping.Column)
+				if source.index == -1 {
+					return nil, fmt.Errorf("column %s mapped to %s not found in the header %s", mapping.Column, column, strings.Join(sourceHeader, ","))
+				}
+			default:
+				source.index = slices.Index(sourceHeader, column)
+				if source.index == -1 && mapping.Default == nil {
+					return nil, fmt.Errorf("column %s not found in the header %s", column, strings.Join(sourceHeader, ","))
+				}
+			}
+		}
+
+		mapper.sources = append(mapper.sources, source)
+	}
+
+	return mapper, nil
+}
+
+func (c *ColumnMapper) Map(line []string) []string {
+	mapped := make([]string, len(c.sources))
+
+	for i, source := range c.sources {
+		switch {
+		case source.value != nil:
+			mapped[i] = *source.value
+		case source.index != -1 && source.index < len(line) && line[source.index] != "":
+			mapped[i] = line[source.index]
+		case source.defaultValue != nil:
+			mapped[i] = *source.defaultValue
+		}
+	}
+
+	return mapped
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"golang.org/x/exp/slices"
+	"testing"
+)
+
+func TestColumnMapper(t *testing.T) {
+	index := func(value int) *int { return &value }
+	text := func(value string) *string { return &value }
+
+	header := []string{"Name", "Date", "Sum", "Currency"}
+
+	tests := []struct {
+		name         string
+		sourceHeader []string
+		columns      map[string]ColumnMapping
+		line         []string
+		expected     []string
+	}{
+		{
+			name:         "columns in another order",
+			sourceHeader: []string{"Sum", "Currency", "Date", "Name"},
+			line:         []string{"10", "EUR", "2022-01-31", "Rent"},
+			expected:     []string{"Rent", "2022-01-31", "10", "EUR"},
+		},
+		{
+			name:         "mapped by name and index",
+			sourceHeader: []string{"Title", "Booked", "Amount", "Currency"},
+			columns:      map[string]ColumnMapping{"Name": {Column: "Title"}, "Date": {Index: index(1)}, "Sum": {Column: "Amount"}},
+			line:         []string{"Rent", "2022-01-31", "10", "EUR"},
+			expected:     []string{"Rent", "2022-01-31", "10", "EUR"},
+		},
+		{
+			name:         "constant value",
+			sourceHeader: []string{"Name", "Date", "Sum", "Currency"},
+			columns:      map[string]ColumnMapping{"Currency": {Value: text("UAH")}},
+			line:         []string{"Rent", "2022-01-31", "10", "EUR"},
+			expected:     []string{"Rent", "2022-01-31", "10", "UAH"},
+		},
+		{
+			name:         "default of an empty value",
+			sourceHeader: []string{"Name", "Date", "Sum", "Currency"},
+			columns:      map[string]ColumnMapping{"Currency": {Default: text("UAH")}},
+			line:         []string{"Rent", "2022-01-31", "10", ""},
+			expected:     []string{"Rent", "2022-01-31", "10", "UAH"},
+		},
+		{
+			name:         "default of a missing column",
+			sourceHeader: []string{"Name", "Date", "Sum"},
+			columns:      map[string]ColumnMapping{"Currency": {Default: text("UAH")}},
+			line:         []string{"Rent", "2022-01-31", "10"},
+			expected:     []string{"Rent", "2022-01-31", "10", "UAH"},
+		},
+		{
+			name:         "short line",
+			sourceHeader: []string{"Name", "Date", "Sum", "Currency"},
+			line:         []string{"Rent", "2022-01-31"},
+			expected:     []string{"Rent", "2022-01-31", "", ""},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mapper, err := NewColumnMapper(header, test.sourceHeader, test.columns)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if mapped := mapper.Map(test.line); !slices.Equal(mapped, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, mapped)
+			}
+		})
+	}
+}
+
+func TestColumnMapperRejectsInvalidMapping(t *testing.T) {
+	index := func(value int) *int { return &value }
+
+	header := []string{"Name", "Sum"}
+	sourceHeader := []string{"Name", "Amount"}
+
+	tests := map[string]map[string]ColumnMapping{
+		"missing column":        nil,
+		"unknown column":        {"Sum": {Column: "Amount"}, "Currency": {Column: "Amount"}},
+		"unknown source column": {"Sum": {Column: "Total"}},
+		"index out of range":    {"Sum": {Index: index(2)}},
+		"negative index":        {"Sum": {Index: index(-1)}},
+	}
+
+	for name, columns := range tests {
+		if _, err := NewColumnMapper(header, sourceHeader, columns); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -2,12 +2,31 @@ package parser
 
 import (
 	"encoding/csv"
-	"github.com/VlasovArtem/hob-migration/src/validator"
+	"fmt"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"io"
 	"os"
 )
 
-func Parse[T any](path string, header []string, parser func(line []string, lineNumber int) (T, error)) ([]T, error) {
+// CSVFormat describes the delimiter and the text encoding of a CSV file, a comma and UTF-8 if not set.
+type CSVFormat struct {
+	Delimiter rune
+	// Encoding is the name of the encoding as in HTML, e.g. windows-1251 or utf-16le
+	Encoding string
+}
+
+// Parse reads a CSV file. The columns of the file are found by the names of the header, or by the column mapping,
+// and every line is passed to the parser in the order of the header with the line of the file where the record starts.
+// The errors of all lines are returned as RowErrors.
+func Parse[T any](
+	path string,
+	format CSVFormat,
+	header []string,
+	columns map[string]ColumnMapping,
+	parser func(line []string, lineNumber int) (T, error),
+) ([]T, error) {
 	open, err := os.Open(path)
 
 	if err != nil {
@@ -17,32 +36,89 @@ func Parse[T any](path string, header []string, parser func(line []string, lineN
 
 	defer open.Close()
 
-	csvReader := csv.NewReader(open)
-	data, err := csvReader.ReadAll()
+	reader, err := NewDecodingReader(open, format.Encoding)
 
 	if err != nil {
-		log.Error().Err(err).Msgf("Can't read file %s", path)
 		return nil, err
 	}
 
+	csvReader := csv.NewReader(reader)
+	// a line with a missing or an extra field is mapped as the other lines, the missing fields are empty
+	csvReader.FieldsPerRecord = -1
+	if format.Delimiter != 0 {
+		csvReader.Comma = format.Delimiter
+	}
+
 	var items []T
+	var rowErrors RowErrors
+	var columnMapper *ColumnMapper
 
 	log.Info().Msgf("Start parsing %s", path)
 
-	for i, line := range data {
-		if i == 0 {
-			if err := validator.VerifyCSVHeader(header, line); err != nil {
+	for {
+		line, err := csvReader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			log.Error().Err(err).Msgf("Can't read file %s", path)
+			return nil, err
+		}
+
+		// the line of the file where the record starts, a quoted field can span several lines
+		lineNumber, _ := csvReader.FieldPos(0)
+
+		if columnMapper == nil {
+			if columnMapper, err = NewColumnMapper(header, line, columns); err != nil {
 				log.Err(err).Msgf("Can't parse file %s", path)
 				return nil, err
 			}
 		} else {
-			if item, err := parser(line, i); err != nil {
-				return nil, err
+			if item, err := parser(columnMapper.Map(line), lineNumber); err != nil {
+				rowErrors = append(rowErrors, ToRowError(err, path, lineNumber))
 			} else {
 				items = append(items, item)
 			}
 		}
 	}
 
-	return items, open.Close()
+	if err := open.Close(); err != nil {
+		return nil, err
+	}
+
+	// the valid lines are returned together with the errors of the invalid ones
+	return items, rowErrorsOf(rowErrors)
+}
+
+// NewDecodingReader decodes the text of the encoding to UTF-8, the reader is returned as is if the encoding is empty.
+func NewDecodingReader(reader io.Reader, encodingName string) (io.Reader, error) {
+	if encodingName == "" {
+		return reader, nil
+	}
+
+	textEncoding, err := lookupEncoding(encodingName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return textEncoding.NewDecoder().Reader(reader), nil
+}
+
+// VerifyEncoding returns an error if the encoding is not supported.
+func VerifyEncoding(encodingName string) error {
+	_, err := lookupEncoding(encodingName)
+	return err
+}
+
+func lookupEncoding(encodingName string) (encoding.Encoding, error) {
+	textEncoding, err := htmlindex.Get(encodingName)
+
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s is not supported", encodingName)
+	}
+
+	return textEncoding, nil
 }
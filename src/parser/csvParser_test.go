@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestParseReportsLinesOfMultiLineRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "incomes.csv")
+	content := "Name,Sum\n" +
+		"\"Salary\nof January\",100\n" +
+		"Bonus,invalid\n" +
+		"\n" +
+		"Gift,also invalid\n"
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []int
+
+	items, err := Parse(path, CSVFormat{}, []string{"Name", "Sum"}, nil, func(line []string, lineNumber int) (string, error) {
+		lines = append(lines, lineNumber)
+
+		if _, err := strconv.Atoi(line[1]); err != nil {
+			return "", NewRowError("Sum", line[1], "sum is not a number")
+		}
+		return line[0], nil
+	})
+
+	if len(items) != 1 || items[0] != "Salary\nof January" {
+		t.Errorf("expected the multi-line name, got %q", items)
+	}
+
+	var rowErrors RowErrors
+
+	if !errors.As(err, &rowErrors) || len(rowErrors) != 2 {
+		t.Fatalf("expected 2 row errors, got %v", err)
+	}
+
+	if rowErrors[0].Line != 4 || rowErrors[1].Line != 6 {
+		t.Errorf("expected the errors at the lines 4 and 6, got %d and %d", rowErrors[0].Line, rowErrors[1].Line)
+	}
+
+	if len(lines) != 3 || lines[0] != 2 {
+		t.Errorf("expected the records at the lines 2, 4 and 6, got %v", lines)
+	}
+}
+
+func TestParseKeepsLinesAfterRaggedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "incomes.csv")
+
+	if err := os.WriteFile(path, []byte("Name,Sum\na,1\nb\nc,x\nd,4,extra\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := Parse(path, CSVFormat{}, []string{"Name", "Sum"}, nil, func(line []string, lineNumber int) (string, error) {
+		if _, err := strconv.Atoi(line[1]); err != nil {
+			return "", NewRowError("Sum", line[1], "sum is not a number")
+		}
+		return line[0], nil
+	})
+
+	if len(items) != 2 || items[0] != "a" || items[1] != "d" {
+		t.Errorf("expected the lines a and d, got %q", items)
+	}
+
+	var rowErrors RowErrors
+
+	if !errors.As(err, &rowErrors) || len(rowErrors) != 2 {
+		t.Fatalf("expected 2 row errors, got %v", err)
+	}
+
+	if rowErrors[0].Line != 3 || rowErrors[1].Line != 4 {
+		t.Errorf("expected the errors at the lines 3 and 4, got %d and %d", rowErrors[0].Line, rowErrors[1].Line)
+	}
+}
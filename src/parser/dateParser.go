@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExcelLayout is the layout of the Excel serial dates, e.g. 44562 is 2022-01-01.
+const ExcelLayout = "excel"
+
+// ISODateTimeLayout is the layout of the dates of XLSX date cells, the dates have no offset.
+const ISODateTimeLayout = "2006-01-02T15:04:05"
+
+// DefaultDateLayouts are the layouts of the dates of a file without layouts.
+var DefaultDateLayouts = []string{"2006-01-02"}
+
+// DateFormat describes the dates of a file.
+type DateFormat struct {
+	// Layouts are the Go layouts of the dates, e.g. 02.01.2006, or ExcelLayout, tried in the order. The dates in
+	// RFC3339 and ISODateTimeLayout are accepted with any layouts, DefaultDateLayouts are used if not set
+	Layouts []string
+	// Location of the dates without an offset, UTC if not set
+	Location *time.Location
+}
+
+// ParseDate parses the date with the layouts of the format and returns it in RFC3339.
+func ParseDate(value string, format DateFormat) (string, error) {
+	date := strings.TrimSpace(value)
+
+	if date == "" {
+		return "", errors.New("date is empty")
+	}
+
+	location := format.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	layouts := format.Layouts
+	if len(layouts) == 0 {
+		layouts = DefaultDateLayouts
+	}
+
+	for _, layout := range append([]string{time.RFC3339, ISODateTimeLayout}, layouts...) {
+		if layout == ExcelLayout {
+			if parsed, ok := parseExcelDate(date, location); ok {
+				return parsed.Format(time.RFC3339), nil
+			}
+			continue
+		}
+
+		if parsed, err := time.ParseInLocation(layout, date, location); err == nil {
+			return parsed.Format(time.RFC3339), nil
+		}
+	}
+
+	return "", fmt.Errorf("date does not match RFC3339 or the layouts %s", strings.Join(layouts, ", "))
+}
+
+// VerifyDateLayout returns an error if the layout has no date elements, such a layout formats every date to itself.
+func VerifyDateLayout(layout string) error {
+	if layout != ExcelLayout && time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC).Format(layout) == layout {
+		return fmt.Errorf("date layout %s has no date elements, expected a Go layout like 02.01.2006 or %s", layout, ExcelLayout)
+	}
+	return nil
+}
+
+// parseExcelDate parses an Excel serial date of the 1900 date system in the location.
+func parseExcelDate(value string, location *time.Location) (time.Time, bool) {
+	serial, err := strconv.ParseFloat(value, 64)
+
+	if err != nil || serial <= 0 {
+		return time.Time{}, false
+	}
+
+	date, err := excelize.ExcelDateToTime(serial, false)
+
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// the serial is a float, the time is rounded to the seconds of the cell
+	date = date.Round(time.Second)
+
+	return time.Date(date.Year(), date.Month(), date.Day(), date.Hour(), date.Minute(), date.Second(), 0, location), true
+}
@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		value    string
+		format   DateFormat
+		expected string
+	}{
+		{value: "2022-01-31", expected: "2022-01-31T00:00:00Z"},
+		{value: " 2022-01-31 ", expected: "2022-01-31T00:00:00Z"},
+		{value: "2022-01-31T10:20:30", expected: "2022-01-31T10:20:30Z"},
+		{value: "2022-01-31T10:20:30+02:00", expected: "2022-01-31T10:20:30+02:00"},
+		{value: "31.01.2022", format: DateFormat{Layouts: []string{"02.01.2006"}}, expected: "2022-01-31T00:00:00Z"},
+		{value: "01/31/2022", format: DateFormat{Layouts: []string{"02.01.2006", "01/02/2006"}}, expected: "2022-01-31T00:00:00Z"},
+		{value: "2022-01-31", format: DateFormat{Location: kyiv}, expected: "2022-01-31T00:00:00+02:00"},
+		{value: "2022-07-31", format: DateFormat{Location: kyiv}, expected: "2022-07-31T00:00:00+03:00"},
+		{value: "2022-01-31T00:00:00Z", format: DateFormat{Location: kyiv}, expected: "2022-01-31T00:00:00Z"},
+		{value: "44592", format: DateFormat{Layouts: []string{ExcelLayout}}, expected: "2022-01-31T00:00:00Z"},
+		{value: "44592.5", format: DateFormat{Layouts: []string{ExcelLayout}}, expected: "2022-01-31T12:00:00Z"},
+		{value: "44592", format: DateFormat{Layouts: []string{ExcelLayout}, Location: kyiv}, expected: "2022-01-31T00:00:00+02:00"},
+	}
+
+	for _, test := range tests {
+		parsed, err := ParseDate(test.value, test.format)
+
+		if err != nil {
+			t.Errorf("%q %v: unexpected error %v", test.value, test.format.Layouts, err)
+		} else if parsed != test.expected {
+			t.Errorf("%q %v: expected %s, got %s", test.value, test.format.Layouts, test.expected, parsed)
+		}
+	}
+}
+
+func TestParseDateRejectsInvalidDates(t *testing.T) {
+	tests := []struct {
+		value  string
+		format DateFormat
+	}{
+		{value: ""},
+		{value: "  "},
+		{value: "31.01.2022"},
+		{value: "2022-02-30"},
+		{value: "44592"},
+		{value: "-1", format: DateFormat{Layouts: []string{ExcelLayout}}},
+		{value: "today", format: DateFormat{Layouts: []string{ExcelLayout, "02.01.2006"}}},
+	}
+
+	for _, test := range tests {
+		if parsed, err := ParseDate(test.value, test.format); err == nil {
+			t.Errorf("%q %v: expected an error, got %s", test.value, test.format.Layouts, parsed)
+		}
+	}
+}
+
+func TestVerifyDateLayout(t *testing.T) {
+	for _, layout := range []string{"2006-01-02", "02.01.2006", "Jan 2, 2006", ExcelLayout} {
+		if err := VerifyDateLayout(layout); err != nil {
+			t.Errorf("%s: unexpected error %v", layout, err)
+		}
+	}
+
+	for _, layout := range []string{"dd.mm.yyyy", "YYYY-MM-DD", ""} {
+		if err := VerifyDateLayout(layout); err == nil {
+			t.Errorf("%s: expected an error", layout)
+		}
+	}
+}
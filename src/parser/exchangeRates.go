@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	exchangeRatesHeader = []string{"Date", "Currency", "Rate"}
+	currencyPattern     = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// ExchangeRates are the rates of the currencies to the target currency by date.
+type ExchangeRates struct {
+	rates map[string][]exchangeRate
+}
+
+type exchangeRate struct {
+	currency string
+	date     time.Time
+	rate     decimal.Decimal
+}
+
+// ReadExchangeRates reads the CSV table of the exchange rates with the Date, Currency and Rate columns. The rate is
+// the amount of the target currency for one unit of the currency on the date.
+func ReadExchangeRates(path string) (*ExchangeRates, error) {
+	rates, err := Parse(path, CSVFormat{}, exchangeRatesHeader, nil, func(line []string, lineNumber int) (exchangeRate, error) {
+		date, err := ParseDate(line[0], DateFormat{})
+
+		if err != nil {
+			return exchangeRate{}, NewRowError("Date", line[0], err.Error())
+		}
+
+		currency := NormalizeCurrency(line[1])
+
+		if err := VerifyCurrency(currency); err != nil {
+			return exchangeRate{}, NewRowError("Currency", line[1], err.Error())
+		}
+
+		rate, err := decimal.NewFromString(strings.TrimSpace(line[2]))
+
+		if err != nil || !rate.IsPositive() {
+			return exchangeRate{}, NewRowError("Rate", line[2], "rate is not a positive number")
+		}
+
+		parsedDate, _ := time.Parse(time.RFC3339, date)
+
+		return exchangeRate{currency: currency, date: civilDate(parsedDate), rate: rate}, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	exchangeRates := &ExchangeRates{rates: make(map[string][]exchangeRate)}
+
+	for _, rate := range rates {
+		exchangeRates.rates[rate.currency] = append(exchangeRates.rates[rate.currency], rate)
+	}
+
+	for _, currencyRates := range exchangeRates.rates {
+		sort.Slice(currencyRates, func(i, j int) bool { return currencyRates[i].date.Before(currencyRates[j].date) })
+	}
+
+	return exchangeRates, nil
+}
+
+// Rate returns the rate of the currency on the date, or the last rate before the date if the table has no rate on
+// the date, e.g. on a weekend.
+func (e *ExchangeRates) Rate(currency string, date time.Time) (decimal.Decimal, error) {
+	day := civilDate(date)
+	currencyRates := e.rates[currency]
+
+	index := sort.Search(len(currencyRates), func(i int) bool { return currencyRates[i].date.After(day) })
+
+	if index == 0 {
+		return decimal.Decimal{}, fmt.Errorf("no exchange rate of %s on or before %s", currency, day.Format("2006-01-02"))
+	}
+
+	return currencyRates[index-1].rate, nil
+}
+
+// NormalizeCurrency returns the upper case currency code without spaces.
+func NormalizeCurrency(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+// VerifyCurrency returns an error if the currency is not an ISO 4217 code, e.g. UAH.
+func VerifyCurrency(currency string) error {
+	if !currencyPattern.MatchString(currency) {
+		return fmt.Errorf("currency %s is not a three letter code like UAH or EUR", currency)
+	}
+	return nil
+}
+
+// civilDate is the day of the date in its location, the rates are published for days.
+func civilDate(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
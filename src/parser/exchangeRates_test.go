@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeExchangeRates(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "rates.csv")
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestExchangeRates(t *testing.T) {
+	rates, err := ReadExchangeRates(writeExchangeRates(t, `Date,Currency,Rate
+2022-01-06,EUR,31.0012
+2022-01-03,eur,30.9226
+2022-01-03, USD ,27.2782
+`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kyiv := time.FixedZone("EET", 2*60*60)
+
+	tests := []struct {
+		currency string
+		date     time.Time
+		expected string
+	}{
+		{currency: "EUR", date: time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC), expected: "30.9226"},
+		{currency: "EUR", date: time.Date(2022, 1, 5, 23, 59, 0, 0, time.UTC), expected: "30.9226"},
+		{currency: "EUR", date: time.Date(2022, 1, 6, 0, 0, 0, 0, kyiv), expected: "31.0012"},
+		{currency: "EUR", date: time.Date(2022, 1, 9, 0, 0, 0, 0, time.UTC), expected: "31.0012"},
+		{currency: "USD", date: time.Date(2022, 1, 4, 0, 0, 0, 0, time.UTC), expected: "27.2782"},
+	}
+
+	for _, test := range tests {
+		rate, err := rates.Rate(test.currency, test.date)
+
+		if err != nil {
+			t.Errorf("%s on %s: unexpected error %v", test.currency, test.date, err)
+		} else if rate.String() != test.expected {
+			t.Errorf("%s on %s: expected %s, got %s", test.currency, test.date, test.expected, rate)
+		}
+	}
+
+	for _, currency := range []string{"EUR", "PLN"} {
+		if _, err := rates.Rate(currency, time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)); err == nil {
+			t.Errorf("expected no rate of %s on 2022-01-02", currency)
+		}
+	}
+}
+
+func TestExchangeRatesRejectInvalidRows(t *testing.T) {
+	_, err := ReadExchangeRates(writeExchangeRates(t, `Date,Currency,Rate
+2022-01-03,EUR,30.9226
+03.01.2022,EUR,30.9226
+2022-01-03,EURO,30.9226
+2022-01-03,EUR,0
+2022-01-03,EUR,abc
+`))
+
+	var rowErrors RowErrors
+
+	if !errors.As(err, &rowErrors) || len(rowErrors) != 4 {
+		t.Fatalf("expected 4 invalid rows, got %v", err)
+	}
+
+	for index, column := range []string{"Date", "Currency", "Rate", "Rate"} {
+		if rowError := rowErrors[index]; rowError.Line != index+3 || rowError.Column != column {
+			t.Errorf("expected the invalid %s at the line %d, got %v", column, index+3, rowError)
+		}
+	}
+}
+
+func TestVerifyCurrency(t *testing.T) {
+	for _, currency := range []string{"UAH", NormalizeCurrency(" eur ")} {
+		if err := VerifyCurrency(currency); err != nil {
+			t.Errorf("%s: unexpected error %v", currency, err)
+		}
+	}
+
+	for _, currency := range []string{"", "uah", "EURO", "₴", "U1H"} {
+		if err := VerifyCurrency(currency); err == nil {
+			t.Errorf("%q: expected an error", currency)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"encoding/json"
+	"github.com/rs/zerolog/log"
+	"os"
+)
+
+// ParseJSON reads a JSON array of records. The records are numbered from 1, the same way as the lines of a CSV file
+// without the header.
+func ParseJSON[RECORD any, T any](path string, parser func(record RECORD, lineNumber int) (T, error)) ([]T, error) {
+	open, err := os.Open(path)
+
+	if err != nil {
+		log.Error().Err(err).Msgf("Can't open file %s", path)
+		return nil, err
+	}
+
+	defer open.Close()
+
+	var records []RECORD
+
+	decoder := json.NewDecoder(open)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&records); err != nil {
+		log.Error().Err(err).Msgf("Can't read file %s", path)
+		return nil, err
+	}
+
+	var items []T
+	var rowErrors RowErrors
+
+	log.Info().Msgf("Start parsing %s", path)
+
+	for i, record := range records {
+		if item, err := parser(record, i+1); err != nil {
+			rowErrors = append(rowErrors, ToRowError(err, path, i+1))
+		} else {
+			items = append(items, item)
+		}
+	}
+
+	if err := open.Close(); err != nil {
+		return nil, err
+	}
+
+	return items, rowErrorsOf(rowErrors)
+}
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/VlasovArtem/hob-migration/src/model"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OFXTransaction is a statement transaction (STMTTRN) of an OFX file. Credits have a positive amount, debits a
+// negative one.
+type OFXTransaction struct {
+	// Number of the transaction in the file, starting from 1
+	Number int
+	Id     string
+	Type   string
+	Date   time.Time
+	Amount model.Money
+	Name   string
+	Memo   string
+}
+
+var (
+	ofxTransactionPattern = regexp.MustCompile(`(?is)<STMTTRN>(.*?)</STMTTRN>`)
+	ofxFieldPattern       = regexp.MustCompile(`(?i)<([A-Z0-9.]+)>([^<]*)`)
+	ofxDatePattern        = regexp.MustCompile(`^(\d{8})(\d{6})?(?:\.\d+)?(?:\[([+-]?\d+(?:\.\d+)?)(?::[^\]]*)?\])?$`)
+)
+
+// ReadOFX reads the statement transactions of an OFX file. Both the SGML (1.x) and the XML (2.x) versions are
+// supported. The valid transactions are returned together with the RowErrors of the invalid ones.
+func ReadOFX(path string) ([]OFXTransaction, error) {
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		log.Error().Err(err).Msgf("Can't open file %s", path)
+		return nil, err
+	}
+
+	log.Info().Msgf("Start parsing %s", path)
+
+	var transactions []OFXTransaction
+	var rowErrors RowErrors
+
+	for index, match := range ofxTransactionPattern.FindAllStringSubmatch(string(content), -1) {
+		fields := make(map[string]string)
+
+		for _, field := range ofxFieldPattern.FindAllStringSubmatch(match[1], -1) {
+			fields[strings.ToUpper(field[1])] = strings.TrimSpace(field[2])
+		}
+
+		transaction, err := parseOFXTransaction(fields)
+
+		if err != nil {
+			rowErrors = append(rowErrors, ToRowError(err, path, index+1))
+			continue
+		}
+
+		transaction.Number = index + 1
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, rowErrorsOf(rowErrors)
+}
+
+func parseOFXTransaction(fields map[string]string) (OFXTransaction, error) {
+	// the decimal separator of OFX amounts is either '.' or ',', thousands are not separated
+	amount, err := decimal.NewFromString(strings.Replace(fields["TRNAMT"], ",", ".", 1))
+
+	if err != nil {
+		return OFXTransaction{}, NewRowError("TRNAMT", fields["TRNAMT"], "amount is not valid")
+	}
+
+	date, err := parseOFXDate(fields["DTPOSTED"])
+
+	if err != nil {
+		return OFXTransaction{}, NewRowError("DTPOSTED", fields["DTPOSTED"], "date is not valid")
+	}
+
+	return OFXTransaction{
+		Id:     fields["FITID"],
+		Type:   fields["TRNTYPE"],
+		Date:   date,
+		Amount: model.NewMoney(amount),
+		Name:   fields["NAME"],
+		Memo:   fields["MEMO"],
+	}, nil
+}
+
+// parseOFXDate parses the OFX datetime YYYYMMDD[HHMMSS[.XXX]][[offset:TZ]], without an offset the date is in GMT.
+func parseOFXDate(value string) (time.Time, error) {
+	match := ofxDatePattern.FindStringSubmatch(value)
+
+	if match == nil {
+		return time.Time{}, fmt.Errorf("date %s is not valid", value)
+	}
+
+	location := time.UTC
+
+	if match[3] != "" {
+		offset, _ := strconv.ParseFloat(match[3], 64)
+		location = time.FixedZone("", int(offset*3600))
+	}
+
+	dateTime := match[1] + match[2]
+	layout := "20060102150405"[:len(dateTime)]
+
+	return time.ParseInLocation(layout, dateTime, location)
+}
@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RowError is an invalid row of a source file. Line is the line of a CSV file or the row of a sheet, the number of
+// the item of a JSON file and the number of the transaction of an OFX file. Line is 0 for the errors of the file.
+type RowError struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column string `json:"column"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+// NewRowError creates the error of a column of a row, the file and the line are set by the parser.
+func NewRowError(column string, value string, reason string) *RowError {
+	return &RowError{
+		Column: column,
+		Value:  value,
+		Reason: reason,
+	}
+}
+
+func (r *RowError) Error() string {
+	if r.Column == "" {
+		return fmt.Sprintf("%s at the line %d of %s", r.Reason, r.Line, r.File)
+	}
+	return fmt.Sprintf("%s: column %s value '%s' at the line %d of %s", r.Reason, r.Column, r.Value, r.Line, r.File)
+}
+
+// RowErrors are all invalid rows of a file.
+type RowErrors []*RowError
+
+func (r RowErrors) Error() string {
+	return fmt.Sprintf("%d invalid rows, first: %s", len(r), r[0].Error())
+}
+
+// ToRowError sets the file and the line of the error, errors of other types become the reason of a RowError.
+func ToRowError(err error, file string, line int) *RowError {
+	var rowError *RowError
+
+	if errors.As(err, &rowError) {
+		located := *rowError
+		located.File, located.Line = file, line
+		return &located
+	}
+
+	return &RowError{File: file, Line: line, Reason: err.Error()}
+}
+
+func rowErrorsOf(rowErrors RowErrors) error {
+	if len(rowErrors) == 0 {
+		return nil
+	}
+	return rowErrors
+}
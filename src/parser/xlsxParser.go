@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/exp/slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseXLSX reads a sheet of the workbook the same way Parse reads a CSV file. The cells are read with their raw
+// values, so numbers are not affected by the number format of the cell, and the cells of the date columns that
+// contain an Excel serial date are converted to ISODateTimeLayout, the dates of Excel have no time zone.
+func ParseXLSX[T any](
+	path string,
+	sheet string,
+	header []string,
+	columns map[string]ColumnMapping,
+	dateColumns []string,
+	parser func(line []string, lineNumber int) (T, error),
+) ([]T, error) {
+	workbook, err := excelize.OpenFile(path)
+
+	if err != nil {
+		log.Error().Err(err).Msgf("Can't open file %s", path)
+		return nil, err
+	}
+
+	defer workbook.Close()
+
+	var date1904 excelize.Date1904
+
+	if err := workbook.GetWorkbookPrOptions(&date1904); err != nil {
+		log.Error().Err(err).Msgf("Can't read workbook properties of %s", path)
+		return nil, err
+	}
+
+	rows, err := workbook.Rows(sheet)
+
+	if err != nil {
+		log.Error().Err(err).Msgf("Can't read sheet %s of the file %s", sheet, path)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var dateIndexes []int
+
+	for index, column := range header {
+		if slices.Contains(dateColumns, column) {
+			dateIndexes = append(dateIndexes, index)
+		}
+	}
+
+	var items []T
+	var rowErrors RowErrors
+	var columnMapper *ColumnMapper
+
+	log.Info().Msgf("Start parsing sheet %s of %s", sheet, path)
+
+	for i := 0; rows.Next(); i++ {
+		line, err := rows.Columns(excelize.Options{RawCellValue: true})
+
+		if err != nil {
+			log.Error().Err(err).Msgf("Can't read sheet %s of the file %s", sheet, path)
+			return nil, err
+		}
+
+		if i == 0 {
+			if columnMapper, err = NewColumnMapper(header, line, columns); err != nil {
+				log.Err(err).Msgf("Can't parse sheet %s of the file %s", sheet, path)
+				return nil, err
+			}
+			continue
+		}
+
+		if isEmptyLine(line) {
+			continue
+		}
+
+		line = columnMapper.Map(line)
+
+		for _, index := range dateIndexes {
+			line[index] = excelDateToISO(line[index], bool(date1904))
+		}
+
+		if item, err := parser(line, i+1); err != nil {
+			rowErrors = append(rowErrors, ToRowError(err, path+"#"+sheet, i+1))
+		} else {
+			items = append(items, item)
+		}
+	}
+
+	if err := rows.Error(); err != nil {
+		return nil, err
+	}
+
+	return items, rowErrorsOf(rowErrors)
+}
+
+func isEmptyLine(line []string) bool {
+	for _, value := range line {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// excelDateToISO converts an Excel serial date, values that are not numbers are returned as is.
+func excelDateToISO(value string, date1904 bool) string {
+	serial, err := strconv.ParseFloat(value, 64)
+
+	if err != nil {
+		return value
+	}
+
+	date, err := excelize.ExcelDateToTime(serial, date1904)
+
+	if err != nil {
+		return value
+	}
+
+	return date.Round(time.Second).Format(ISODateTimeLayout)
+}
@@ -9,7 +9,7 @@ import (
 	"strings"
 )
 
-var SupportedTypes = []string{"csv"}
+var SupportedTypes = []string{"csv", "json", "xlsx", "ofx"}
 
 type Validator interface {
 	Verify() error
@@ -33,10 +33,12 @@ func VerifyFilePathIsEmpty(path string, message string) error {
 }
 
 func VerifyFilePathTypeIsValid(path string) error {
-	ext := strings.Replace(filepath.Ext(path), ".", "", 1)
+	return VerifyFormatIsValid(strings.Replace(filepath.Ext(path), ".", "", 1))
+}
 
-	if !slices.Contains(SupportedTypes, ext) {
-		return errors.New(fmt.Sprintf("format %s not supported. Supported formats: %s", ext,
+func VerifyFormatIsValid(format string) error {
+	if !slices.Contains(SupportedTypes, format) {
+		return errors.New(fmt.Sprintf("format %s not supported. Supported formats: %s", format,
 			strings.Join(SupportedTypes, ",")))
 	}
 